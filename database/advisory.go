@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"hash/fnv"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	advisoryXactLockQuery    = `SELECT pg_advisory_xact_lock($1)`
+	tryAdvisoryXactLockQuery = `SELECT pg_try_advisory_xact_lock($1)`
+)
+
+// AdvisoryKey hashes name with 64-bit FNV-1a into the key space of
+// pg_advisory_lock. The hash is stable across processes and releases, so
+// every replica derives the same key from the same name.
+func AdvisoryKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+
+	return int64(h.Sum64())
+}
+
+// WithAdvisoryLock runs fn while holding the session-level advisory lock for
+// key, waiting for other holders to release it. The lock is taken on a
+// connection acquired from pool for the duration of the call, and fn is given
+// a Repo on that connection.
+func WithAdvisoryLock(ctx context.Context, pool *pgxpool.Pool, key string, fn func(Repo) error) error {
+	return withAdvisoryLock(ctx, pool, "WithAdvisoryLock", `SELECT true FROM pg_advisory_lock($1)`, key, fn)
+}
+
+// TryWithAdvisoryLock is like WithAdvisoryLock, but returns
+// ErrLockNotAvailable without running fn if the lock is already held.
+func TryWithAdvisoryLock(ctx context.Context, pool *pgxpool.Pool, key string, fn func(Repo) error) error {
+	return withAdvisoryLock(ctx, pool, "TryWithAdvisoryLock", `SELECT pg_try_advisory_lock($1)`, key, fn)
+}
+
+func withAdvisoryLock(ctx context.Context, pool *pgxpool.Pool, op string, sql string, key string, fn func(Repo) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return wrapError(op, err)
+	}
+	defer conn.Release()
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, sql, AdvisoryKey(key)).Scan(&acquired); err != nil {
+		return wrapError(op, err)
+	}
+
+	if !acquired {
+		return &Error{Op: op, Code: CodeLockNotAvailable, Err: ErrLockNotAvailable}
+	}
+
+	defer func() {
+		// The lock outlives ctx, so it is released regardless. If that fails
+		// the connection is closed, which releases it too, rather than
+		// returned to the pool still holding it.
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, AdvisoryKey(key)); err != nil {
+			_ = conn.Conn().Close(context.Background())
+		}
+	}()
+
+	return fn(Repo{dbtx: conn})
+}
+
+// AdvisoryXactLock takes the transaction-level advisory lock for key, waiting
+// for other holders to release it. The lock is released when the transaction
+// ends; outside a transaction it is released as soon as it is taken.
+func (r Repo) AdvisoryXactLock(ctx context.Context, key string) error {
+	if _, err := r.dbtx.Exec(ctx, advisoryXactLockQuery, AdvisoryKey(key)); err != nil {
+		return wrapError("AdvisoryXactLock", err)
+	}
+
+	return nil
+}
+
+// TryAdvisoryXactLock is like AdvisoryXactLock, but returns
+// ErrLockNotAvailable instead of waiting if the lock is already held.
+func (r Repo) TryAdvisoryXactLock(ctx context.Context, key string) error {
+	var acquired bool
+	if err := r.dbtx.QueryRow(ctx, tryAdvisoryXactLockQuery, AdvisoryKey(key)).Scan(&acquired); err != nil {
+		return wrapError("TryAdvisoryXactLock", err)
+	}
+
+	if !acquired {
+		return &Error{Op: "TryAdvisoryXactLock", Code: CodeLockNotAvailable, Err: ErrLockNotAvailable}
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrBatchNotSent = errors.New("batch not sent")
+
+// Batch queues repository operations so that Repo.SendBatch can run them in a
+// single round trip. A Batch must only be sent once.
+type Batch struct {
+	batch pgx.Batch
+	reads []func(pgx.BatchResults)
+}
+
+// BatchResult holds the outcome of one queued operation. It is filled in when
+// the batch is sent.
+type BatchResult[T any] struct {
+	value T
+	err   error
+}
+
+func newBatchResult[T any]() *BatchResult[T] {
+	return &BatchResult[T]{err: ErrBatchNotSent}
+}
+
+func (r *BatchResult[T]) Get() (T, error) {
+	return r.value, r.err
+}
+
+func (r *BatchResult[T]) Err() error {
+	return r.err
+}
+
+func (b *Batch) Len() int {
+	return b.batch.Len()
+}
+
+func (b *Batch) InsertSample(name string) *BatchResult[Sample] {
+	result := newBatchResult[Sample]()
+
+	b.batch.Queue(insertSampleQuery, name)
+	b.reads = append(b.reads, func(br pgx.BatchResults) {
+		sample, err := scanSample(br.QueryRow())
+		if err != nil {
+			result.err = wrapError("InsertSample", err)
+			return
+		}
+
+		result.value, result.err = sample, nil
+	})
+
+	return result
+}
+
+func (b *Batch) UpdateSample(id string, name string) *BatchResult[struct{}] {
+	result := newBatchResult[struct{}]()
+
+	b.batch.Queue(updateSampleQuery, name, id)
+	b.reads = append(b.reads, func(br pgx.BatchResults) {
+		tag, err := br.Exec()
+		if err != nil {
+			result.err = wrapError("UpdateSample", err)
+			return
+		}
+
+		if tag.RowsAffected() == 0 {
+			result.err = wrapError("UpdateSample", ErrNotFound)
+			return
+		}
+
+		result.err = nil
+	})
+
+	return result
+}
+
+func (b *Batch) FindSampleByID(id string) *BatchResult[Sample] {
+	result := newBatchResult[Sample]()
+
+	b.batch.Queue(findSampleQuery("", nil), id)
+	b.reads = append(b.reads, func(br pgx.BatchResults) {
+		sample, err := scanSample(br.QueryRow())
+		if err != nil {
+			result.err = wrapError("FindSampleByID", err)
+			return
+		}
+
+		result.value, result.err = sample, nil
+	})
+
+	return result
+}
+
+// SendBatch runs every operation queued on b in one round trip and fills in
+// their results. Check each result for its own error: once a statement fails
+// the rest of the batch is aborted, and SendBatch may also report that failure.
+func (r Repo) SendBatch(ctx context.Context, b *Batch) error {
+	if b.batch.Len() == 0 {
+		return nil
+	}
+
+	br := r.dbtx.SendBatch(ctx, &b.batch)
+
+	for _, read := range b.reads {
+		read(br)
+	}
+
+	if err := br.Close(); err != nil {
+		return wrapError("SendBatch", err)
+	}
+
+	return nil
+}
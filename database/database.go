@@ -2,93 +2,129 @@ package database
 
 import (
 	"context"
+	"strconv"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-type Database struct {
-	pool *pgxpool.Pool
-}
+type Database = Repo
 
-func NewPool(dsn string) (*pgxpool.Pool, error) {
-	ctx := context.Background()
+type Option func(*pgxpool.Config)
 
-	conn, err := pgxpool.ParseConfig(dsn)
-	if err != nil {
-		return nil, err
+func WithMaxConns(n int32) Option {
+	return func(cfg *pgxpool.Config) {
+		cfg.MaxConns = n
 	}
+}
 
-	pool, err := pgxpool.NewWithConfig(ctx, conn)
-	if err != nil {
-		return nil, err
+func WithMinConns(n int32) Option {
+	return func(cfg *pgxpool.Config) {
+		cfg.MinConns = n
 	}
+}
 
-	if err := pool.Ping(ctx); err != nil {
-		return nil, err
+func WithMaxConnLifetime(d time.Duration) Option {
+	return func(cfg *pgxpool.Config) {
+		cfg.MaxConnLifetime = d
 	}
+}
 
-	return pool, nil
+func WithMaxConnIdleTime(d time.Duration) Option {
+	return func(cfg *pgxpool.Config) {
+		cfg.MaxConnIdleTime = d
+	}
 }
 
-func NewDatabase(pool *pgxpool.Pool) (Database, error) {
-	return Database{pool: pool}, nil
+func WithHealthCheckPeriod(d time.Duration) Option {
+	return func(cfg *pgxpool.Config) {
+		cfg.HealthCheckPeriod = d
+	}
 }
 
-func (db Database) InsertSample(ctx context.Context, name string) (Sample, error) {
-	var id string
+func WithApplicationName(name string) Option {
+	return func(cfg *pgxpool.Config) {
+		cfg.ConnConfig.RuntimeParams["application_name"] = name
+	}
+}
 
-	if err := db.pool.QueryRow(ctx, `INSERT INTO samples (name) VALUES ($1) RETURNING id`, name).Scan(&id); err != nil {
-		return Sample{}, err
+func WithStatementTimeout(d time.Duration) Option {
+	return func(cfg *pgxpool.Config) {
+		cfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(d.Milliseconds(), 10)
 	}
+}
 
-	return Sample{ID: id, Name: name}, nil
+func WithLockTimeout(d time.Duration) Option {
+	return func(cfg *pgxpool.Config) {
+		cfg.ConnConfig.RuntimeParams["lock_timeout"] = strconv.FormatInt(d.Milliseconds(), 10)
+	}
 }
 
-func (db Database) UpdateSample(ctx context.Context, id string, name string) error {
-	if _, err := db.pool.Exec(ctx, `UPDATE samples SET name = $1 WHERE id = $2`, name, id); err != nil {
-		return err
+func WithDefaultQueryExecMode(mode pgx.QueryExecMode) Option {
+	return func(cfg *pgxpool.Config) {
+		cfg.ConnConfig.DefaultQueryExecMode = mode
 	}
+}
 
-	return nil
+// WithAfterConnect runs fn on every new connection before it joins the pool.
+// Hooks from repeated options run in the order given.
+func WithAfterConnect(fn func(context.Context, *pgx.Conn) error) Option {
+	return func(cfg *pgxpool.Config) {
+		prev := cfg.AfterConnect
+		cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			if prev != nil {
+				if err := prev(ctx, conn); err != nil {
+					return err
+				}
+			}
+
+			return fn(ctx, conn)
+		}
+	}
 }
 
-func (db Database) FindSampleByID(ctx context.Context, id string) (Sample, error) {
-	var sample Sample
+// WithTracer installs tracer on every connection. It may also implement
+// pgx.BatchTracer, pgx.CopyFromTracer and pgx.ConnectTracer. Tracers from
+// repeated options are all called.
+func WithTracer(tracer pgx.QueryTracer) Option {
+	return func(cfg *pgxpool.Config) {
+		if cfg.ConnConfig.Tracer == nil {
+			cfg.ConnConfig.Tracer = tracer
+			return
+		}
 
-	if err := db.pool.QueryRow(ctx, `SELECT id, name FROM samples WHERE id = $1`, id).Scan(&sample.ID, &sample.Name); err != nil {
-		return Sample{}, err
+		cfg.ConnConfig.Tracer = multitracer.New(cfg.ConnConfig.Tracer, tracer)
 	}
-
-	return sample, nil
 }
 
-func (db Database) ListSamples(ctx context.Context) ([]Sample, error) {
-	rows, err := db.pool.Query(ctx, `SELECT id, name FROM samples`)
+// NewPool connects to dsn and pings the server. ctx bounds the initial
+// connection, so a deadline on it acts as the connect timeout.
+func NewPool(ctx context.Context, dsn string, opts ...Option) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var samples []Sample
-	for rows.Next() {
-		var sample Sample
-		if err := rows.Scan(&sample.ID, &sample.Name); err != nil {
-			return nil, err
-		}
-		samples = append(samples, sample)
+	for _, opt := range opts {
+		opt(cfg)
 	}
 
-	if err := rows.Err(); err != nil {
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
 		return nil, err
 	}
 
-	return samples, nil
-}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
 
-func (db Database) DeleteSamples(ctx context.Context) error {
-	if _, err := db.pool.Exec(ctx, `DELETE FROM samples`); err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return pool, nil
+}
+
+func NewDatabase(pool *pgxpool.Pool) (Database, error) {
+	return NewRepo(pool)
 }
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrConflict         = errors.New("conflict")
+	ErrLockNotAvailable = errors.New("lock not available")
+)
+
+// SQLSTATE codes reported by PostgreSQL.
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	CodeNotNullViolation     = "23502"
+	CodeForeignKeyViolation  = "23503"
+	CodeUniqueViolation      = "23505"
+	CodeCheckViolation       = "23514"
+	CodeSerializationFailure = "40001"
+	CodeDeadlockDetected     = "40P01"
+	CodeLockNotAvailable     = "55P03"
+)
+
+// Error is returned by every repository method. It records the operation that
+// failed and, when the server rejected the statement, the SQLSTATE details.
+type Error struct {
+	Op         string
+	Code       string
+	Constraint string
+	Table      string
+	Column     string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return e.Op + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports a NOWAIT lock failure as ErrLockNotAvailable.
+func (e *Error) Is(target error) bool {
+	return target == ErrLockNotAvailable && e.Code == CodeLockNotAvailable
+}
+
+func wrapError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+
+	e := &Error{Op: op, Err: err}
+
+	var (
+		pgErr *pgconn.PgError
+		pqErr *pq.Error
+	)
+	switch {
+	case errors.As(err, &pgErr):
+		e.Code = pgErr.Code
+		e.Constraint = pgErr.ConstraintName
+		e.Table = pgErr.TableName
+		e.Column = pgErr.ColumnName
+	case errors.As(err, &pqErr):
+		e.Code = string(pqErr.Code)
+		e.Constraint = pqErr.Constraint
+		e.Table = pqErr.Table
+		e.Column = pqErr.Column
+	}
+
+	return e
+}
+
+func code(err error) string {
+	var e *Error
+	if errors.As(err, &e) && e.Code != "" {
+		return e.Code
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+
+	return ""
+}
+
+func IsNotNullViolation(err error) bool {
+	return code(err) == CodeNotNullViolation
+}
+
+func IsForeignKeyViolation(err error) bool {
+	return code(err) == CodeForeignKeyViolation
+}
+
+func IsUniqueViolation(err error) bool {
+	return code(err) == CodeUniqueViolation
+}
+
+func IsCheckViolation(err error) bool {
+	return code(err) == CodeCheckViolation
+}
+
+func IsSerializationFailure(err error) bool {
+	return code(err) == CodeSerializationFailure
+}
+
+func IsDeadlock(err error) bool {
+	return code(err) == CodeDeadlockDetected
+}
+
+func IsLockNotAvailable(err error) bool {
+	return code(err) == CodeLockNotAvailable
+}
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	setActorQuery       = `SELECT set_config($1, $2, true)`
+	sampleHistoryQuery  = `SELECT id, sample_id, operation, old_row, new_row, COALESCE(actor, ''), changed_at FROM samples_history WHERE sample_id = $1 ORDER BY changed_at, id`
+	findSampleAsOfQuery = `SELECT new_row FROM samples_history WHERE sample_id = $1 AND changed_at <= $2 ORDER BY changed_at DESC, id DESC LIMIT 1`
+)
+
+// ActorSetting is the session setting the samples_record_history trigger
+// reads the actor from.
+const ActorSetting = "app.actor"
+
+type actorKey struct{}
+
+// WithActor attributes every change made in transactions begun with ctx by
+// WithTx or RunInTx to actor in samples_history. Use SetActor on a
+// transaction begun elsewhere. Changes made outside a transaction are
+// recorded without an actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// SetActor attributes the changes made in the rest of the current transaction
+// to actor in samples_history. The setting is local to the transaction, so it
+// never leaks to the next user of the connection; outside a transaction it
+// only lasts for this statement and has no effect.
+func (r Repo) SetActor(ctx context.Context, actor string) error {
+	if _, err := r.dbtx.Exec(ctx, setActorQuery, ActorSetting, actor); err != nil {
+		return wrapError("SetActor", err)
+	}
+
+	return nil
+}
+
+func actorFrom(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
+
+// setActor applies the actor in ctx, if any, to tx.
+func setActor(ctx context.Context, tx pgx.Tx) error {
+	actor, ok := actorFrom(ctx)
+	if !ok {
+		return nil
+	}
+
+	return Repo{dbtx: tx}.SetActor(ctx, actor)
+}
+
+// SampleChange is one entry of a sample's history. Old is nil for inserts and
+// New is nil for deletes; soft deletes and restores are updates.
+type SampleChange struct {
+	ID        int64
+	SampleID  string
+	Operation ChangeOp
+	Old       *Sample
+	New       *Sample
+	Actor     string
+	ChangedAt time.Time
+}
+
+func (r Repo) SampleHistory(ctx context.Context, id string) ([]SampleChange, error) {
+	rows, err := r.dbtx.Query(ctx, sampleHistoryQuery, id)
+	if err != nil {
+		return nil, wrapError("SampleHistory", err)
+	}
+	defer rows.Close()
+
+	changes, err := collectChanges(rows)
+	if err != nil {
+		return nil, wrapError("SampleHistory", err)
+	}
+
+	if len(changes) == 0 {
+		return nil, wrapError("SampleHistory", pgx.ErrNoRows)
+	}
+
+	return changes, nil
+}
+
+// sampleRow is a samples row as encoded by to_jsonb in samples_history.
+type sampleRow struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Version   int64      `json:"version"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+func collectChanges(rows rowsScanner) ([]SampleChange, error) {
+	var changes []SampleChange
+	for rows.Next() {
+		var (
+			change         SampleChange
+			oldRow, newRow []byte
+			err            error
+		)
+		if err := rows.Scan(&change.ID, &change.SampleID, &change.Operation, &oldRow, &newRow, &change.Actor, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+
+		if change.Old, err = unmarshalSample(oldRow); err != nil {
+			return nil, err
+		}
+
+		if change.New, err = unmarshalSample(newRow); err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func unmarshalSample(data []byte) (*Sample, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	var row sampleRow
+	if err := json.Unmarshal(data, &row); err != nil {
+		return nil, err
+	}
+
+	return &Sample{
+		ID:        row.ID,
+		Name:      row.Name,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+		Version:   row.Version,
+		DeletedAt: row.DeletedAt,
+	}, nil
+}
+
+// FindSampleAsOf returns the sample as it was at t, according to its history.
+// Changes made before samples_history was created are not known to it.
+func (r Repo) FindSampleAsOf(ctx context.Context, id string, t time.Time, opts ...FindOption) (Sample, error) {
+	var data []byte
+	if err := r.dbtx.QueryRow(ctx, findSampleAsOfQuery, id, t).Scan(&data); err != nil {
+		return Sample{}, wrapError("FindSampleAsOf", err)
+	}
+
+	sample, err := sampleAsOf(data, opts)
+	if err != nil {
+		return Sample{}, wrapError("FindSampleAsOf", err)
+	}
+
+	return sample, nil
+}
+
+// sampleAsOf decodes the new_row of the last change before the requested
+// time. A deleted sample, or a soft-deleted one unless IncludeDeleted is set,
+// is not found.
+func sampleAsOf(data []byte, opts []FindOption) (Sample, error) {
+	var o findOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	sample, err := unmarshalSample(data)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	if sample == nil || (sample.DeletedAt != nil && !o.includeDeleted) {
+		return Sample{}, ErrNotFound
+	}
+
+	return *sample, nil
+}
@@ -0,0 +1,71 @@
+package database
+
+import "context"
+
+const claimSamplesQuery = samplesQuery + ` LIMIT $1 FOR UPDATE`
+
+// LockOption configures how a locking read treats rows locked by another
+// transaction. Only the locking reads accept it.
+type LockOption func(*lockOptions)
+
+type lockOptions struct {
+	wait string
+}
+
+func newLockOptions(wait string, opts []LockOption) lockOptions {
+	o := lockOptions{wait: wait}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// NoWait makes a locking read fail with ErrLockNotAvailable instead of
+// waiting when the row is locked by another transaction.
+func NoWait() LockOption {
+	return func(o *lockOptions) {
+		o.wait = ` NOWAIT`
+	}
+}
+
+// SkipLocked makes a locking read treat a row locked by another transaction
+// as missing, so it returns ErrNotFound instead of waiting.
+func SkipLocked() LockOption {
+	return func(o *lockOptions) {
+		o.wait = ` SKIP LOCKED`
+	}
+}
+
+// FindSampleForUpdate reads the sample and locks it against concurrent
+// updates, deletes and locking reads until the transaction ends. Outside a
+// transaction the lock is released as soon as the statement completes.
+func (r Repo) FindSampleForUpdate(ctx context.Context, id string, opts ...LockOption) (Sample, error) {
+	return r.findSample(ctx, "FindSampleForUpdate", id, `FOR UPDATE`+newLockOptions("", opts).wait, nil)
+}
+
+// FindSampleForShare reads the sample and locks it against concurrent updates
+// and deletes while still allowing other FOR SHARE readers.
+func (r Repo) FindSampleForShare(ctx context.Context, id string, opts ...LockOption) (Sample, error) {
+	return r.findSample(ctx, "FindSampleForShare", id, `FOR SHARE`+newLockOptions("", opts).wait, nil)
+}
+
+// ClaimSamples locks up to limit of the oldest live samples that no other
+// transaction holds, so concurrent workers never claim the same sample. With
+// NoWait it fails with ErrLockNotAvailable instead of skipping a locked sample.
+func (r Repo) ClaimSamples(ctx context.Context, limit int, opts ...LockOption) ([]Sample, error) {
+	o := newLockOptions(` SKIP LOCKED`, opts)
+
+	rows, err := r.dbtx.Query(ctx, claimSamplesQuery+o.wait, limit)
+	if err != nil {
+		return nil, wrapError("ClaimSamples", err)
+	}
+	defer rows.Close()
+
+	samples, err := collectSamples(rows)
+	if err != nil {
+		return nil, wrapError("ClaimSamples", err)
+	}
+
+	return samples, nil
+}
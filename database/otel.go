@@ -0,0 +1,513 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"iter"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/otakakot/sample-go-postgresql-test/database"
+
+// OTelTracer records an OpenTelemetry client span and a duration measurement
+// for every query, batch, COPY and connect made through a pool. Spans are
+// named after the operation and table, and carry the repository method that
+// issued them as code.function.name. Wrap the repository with
+// NewTracedRepository to group them under one span per method call.
+type OTelTracer struct {
+	tracer   oteltrace.Tracer
+	duration metric.Float64Histogram
+}
+
+func NewOTelTracer(tp oteltrace.TracerProvider, mp metric.MeterProvider) (*OTelTracer, error) {
+	duration, err := mp.Meter(instrumentationName).Float64Histogram(
+		"db.client.operation.duration",
+		metric.WithDescription("Duration of database client operations."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OTelTracer{
+		tracer:   tp.Tracer(instrumentationName),
+		duration: duration,
+	}, nil
+}
+
+var (
+	_ pgx.QueryTracer    = (*OTelTracer)(nil)
+	_ pgx.BatchTracer    = (*OTelTracer)(nil)
+	_ pgx.CopyFromTracer = (*OTelTracer)(nil)
+	_ pgx.ConnectTracer  = (*OTelTracer)(nil)
+)
+
+type otelKey struct{}
+
+type otelTrace struct {
+	start time.Time
+	attrs []attribute.KeyValue
+}
+
+func (t *OTelTracer) begin(ctx context.Context, operation, table string, attrs ...attribute.KeyValue) context.Context {
+	attrs = append(attrs, semconv.DBSystemNamePostgreSQL, semconv.DBOperationName(operation))
+
+	if table != "" {
+		attrs = append(attrs, semconv.DBCollectionName(table))
+	}
+
+	if function := caller(); function != "" {
+		attrs = append(attrs, semconv.CodeFunctionName(function))
+	}
+
+	name := operation
+	if table != "" {
+		name += " " + table
+	}
+
+	ctx, _ = t.tracer.Start(ctx, name, oteltrace.WithSpanKind(oteltrace.SpanKindClient), oteltrace.WithAttributes(attrs...))
+
+	return context.WithValue(ctx, otelKey{}, otelTrace{start: time.Now(), attrs: attrs})
+}
+
+func (t *OTelTracer) end(ctx context.Context, err error) {
+	span := oteltrace.SpanFromContext(ctx)
+	tr, _ := ctx.Value(otelKey{}).(otelTrace)
+
+	attrs := metricAttrs(tr.attrs)
+
+	if err != nil {
+		attrs = append(attrs, semconv.ErrorTypeKey.String(errorType(err)))
+		recordError(span, err)
+	}
+
+	t.duration.Record(ctx, time.Since(tr.start).Seconds(), metric.WithAttributes(attrs...))
+
+	span.End()
+}
+
+// errorType is the SQLSTATE code of err, or _OTHER when the server did not
+// report one, so that error.type stays low-cardinality.
+func errorType(err error) string {
+	return cmp.Or(code(err), "_OTHER")
+}
+
+func recordError(span oteltrace.Span, err error) {
+	if c := code(err); c != "" {
+		span.SetAttributes(semconv.DBResponseStatusCode(c))
+	}
+
+	span.SetAttributes(semconv.ErrorTypeKey.String(errorType(err)))
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// metricAttrs drops the attributes that are too high-cardinality to be used
+// as metric dimensions.
+func metricAttrs(attrs []attribute.KeyValue) []attribute.KeyValue {
+	filtered := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		if attr.Key == semconv.DBQueryTextKey {
+			continue
+		}
+
+		filtered = append(filtered, attr)
+	}
+
+	return filtered
+}
+
+func (t *OTelTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	operation, table := parseStatement(data.SQL)
+
+	return t.begin(ctx, operation, table, semconv.DBQueryText(data.SQL))
+}
+
+func (t *OTelTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.end(ctx, data.Err)
+}
+
+func (t *OTelTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	return t.begin(ctx, "BATCH", "", semconv.DBOperationBatchSize(data.Batch.Len()))
+}
+
+func (t *OTelTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	attrs := []attribute.KeyValue{semconv.DBQueryText(data.SQL)}
+	if data.Err != nil {
+		attrs = append(attrs, semconv.ErrorTypeKey.String(errorType(data.Err)))
+	}
+
+	oteltrace.SpanFromContext(ctx).AddEvent("query", oteltrace.WithAttributes(attrs...))
+}
+
+func (t *OTelTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	t.end(ctx, data.Err)
+}
+
+func (t *OTelTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	return t.begin(ctx, "COPY", strings.Join(data.TableName, "."))
+}
+
+func (t *OTelTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	t.end(ctx, data.Err)
+}
+
+func (t *OTelTracer) TraceConnectStart(ctx context.Context, _ pgx.TraceConnectStartData) context.Context {
+	return t.begin(ctx, "CONNECT", "")
+}
+
+func (t *OTelTracer) TraceConnectEnd(ctx context.Context, data pgx.TraceConnectEndData) {
+	t.end(ctx, data.Err)
+}
+
+// TracedRepository records one span per call of the Repository it wraps,
+// named after the method. The statement spans OTelTracer records during the
+// call are its children.
+type TracedRepository struct {
+	repo   Repository
+	tracer oteltrace.Tracer
+}
+
+var _ SampleRepository = (*TracedRepository)(nil)
+
+func NewTracedRepository(repo Repository, tp oteltrace.TracerProvider) (*TracedRepository, error) {
+	return &TracedRepository{
+		repo:   repo,
+		tracer: tp.Tracer(instrumentationName),
+	}, nil
+}
+
+// start opens the method span. db.operation.name is left to the statement
+// spans, since a method is not a database operation.
+func (r *TracedRepository) start(ctx context.Context, method string) (context.Context, oteltrace.Span) {
+	return r.tracer.Start(ctx, method, oteltrace.WithAttributes(
+		semconv.DBSystemNamePostgreSQL,
+		semconv.DBCollectionName("samples"),
+		semconv.CodeFunctionName(instrumentationName+".(*TracedRepository)."+method),
+	))
+}
+
+func finish(span oteltrace.Span, err error) {
+	if err != nil {
+		recordError(span, err)
+	}
+
+	span.End()
+}
+
+func (r *TracedRepository) InsertSample(ctx context.Context, name string) (Sample, error) {
+	ctx, span := r.start(ctx, "InsertSample")
+	sample, err := r.repo.InsertSample(ctx, name)
+	finish(span, err)
+
+	return sample, err
+}
+
+func (r *TracedRepository) InsertSampleIdempotent(ctx context.Context, key string, name string) (Sample, error) {
+	ctx, span := r.start(ctx, "InsertSampleIdempotent")
+	sample, err := r.repo.InsertSampleIdempotent(ctx, key, name)
+	finish(span, err)
+
+	return sample, err
+}
+
+func (r *TracedRepository) UpsertSampleByName(ctx context.Context, name string) (Sample, error) {
+	ctx, span := r.start(ctx, "UpsertSampleByName")
+	sample, err := r.repo.UpsertSampleByName(ctx, name)
+	finish(span, err)
+
+	return sample, err
+}
+
+func (r *TracedRepository) InsertSamples(ctx context.Context, names []string) ([]Sample, error) {
+	ctx, span := r.start(ctx, "InsertSamples")
+	span.SetAttributes(semconv.DBOperationBatchSize(len(names)))
+	samples, err := r.repo.InsertSamples(ctx, names)
+	finish(span, err)
+
+	return samples, err
+}
+
+func (r *TracedRepository) UpdateSample(ctx context.Context, id string, name string) error {
+	ctx, span := r.start(ctx, "UpdateSample")
+	err := r.repo.UpdateSample(ctx, id, name)
+	finish(span, err)
+
+	return err
+}
+
+func (r *TracedRepository) UpdateSampleIfVersion(ctx context.Context, id string, name string, expectedVersion int64) (Sample, error) {
+	ctx, span := r.start(ctx, "UpdateSampleIfVersion")
+	sample, err := r.repo.UpdateSampleIfVersion(ctx, id, name, expectedVersion)
+	finish(span, err)
+
+	return sample, err
+}
+
+func (r *TracedRepository) FindSampleByID(ctx context.Context, id string, opts ...FindOption) (Sample, error) {
+	ctx, span := r.start(ctx, "FindSampleByID")
+	sample, err := r.repo.FindSampleByID(ctx, id, opts...)
+	finish(span, err)
+
+	return sample, err
+}
+
+func (r *TracedRepository) ListSamples(ctx context.Context, params ListSamplesParams) (SamplePage, error) {
+	ctx, span := r.start(ctx, "ListSamples")
+	page, err := r.repo.ListSamples(ctx, params)
+	finish(span, err)
+
+	return page, err
+}
+
+// Samples records a span that lasts until the iteration stops.
+func (r *TracedRepository) Samples(ctx context.Context) iter.Seq2[Sample, error] {
+	return func(yield func(Sample, error) bool) {
+		ctx, span := r.start(ctx, "Samples")
+
+		var failed error
+		for sample, err := range r.repo.Samples(ctx) {
+			if err != nil {
+				failed = err
+			}
+
+			if !yield(sample, err) {
+				break
+			}
+		}
+
+		finish(span, failed)
+	}
+}
+
+func (r *TracedRepository) DeleteSamples(ctx context.Context) error {
+	ctx, span := r.start(ctx, "DeleteSamples")
+	err := r.repo.DeleteSamples(ctx)
+	finish(span, err)
+
+	return err
+}
+
+func (r *TracedRepository) DeleteSample(ctx context.Context, id string) error {
+	ctx, span := r.start(ctx, "DeleteSample")
+	err := r.repo.DeleteSample(ctx, id)
+	finish(span, err)
+
+	return err
+}
+
+func (r *TracedRepository) RestoreSample(ctx context.Context, id string) error {
+	ctx, span := r.start(ctx, "RestoreSample")
+	err := r.repo.RestoreSample(ctx, id)
+	finish(span, err)
+
+	return err
+}
+
+func (r *TracedRepository) PurgeDeletedBefore(ctx context.Context, t time.Time) (int64, error) {
+	ctx, span := r.start(ctx, "PurgeDeletedBefore")
+	n, err := r.repo.PurgeDeletedBefore(ctx, t)
+	finish(span, err)
+
+	return n, err
+}
+
+func (r *TracedRepository) CopySamples(ctx context.Context, src pgx.CopyFromSource) (int64, error) {
+	ctx, span := r.start(ctx, "CopySamples")
+	n, err := r.repo.CopySamples(ctx, src)
+	finish(span, err)
+
+	return n, err
+}
+
+func (r *TracedRepository) SendBatch(ctx context.Context, b *Batch) error {
+	ctx, span := r.start(ctx, "SendBatch")
+	span.SetAttributes(semconv.DBOperationBatchSize(b.Len()))
+	err := r.repo.SendBatch(ctx, b)
+	finish(span, err)
+
+	return err
+}
+
+func (r *TracedRepository) FindSampleForUpdate(ctx context.Context, id string, opts ...LockOption) (Sample, error) {
+	ctx, span := r.start(ctx, "FindSampleForUpdate")
+	sample, err := r.repo.FindSampleForUpdate(ctx, id, opts...)
+	finish(span, err)
+
+	return sample, err
+}
+
+func (r *TracedRepository) FindSampleForShare(ctx context.Context, id string, opts ...LockOption) (Sample, error) {
+	ctx, span := r.start(ctx, "FindSampleForShare")
+	sample, err := r.repo.FindSampleForShare(ctx, id, opts...)
+	finish(span, err)
+
+	return sample, err
+}
+
+func (r *TracedRepository) ClaimSamples(ctx context.Context, limit int, opts ...LockOption) ([]Sample, error) {
+	ctx, span := r.start(ctx, "ClaimSamples")
+	samples, err := r.repo.ClaimSamples(ctx, limit, opts...)
+	finish(span, err)
+
+	return samples, err
+}
+
+func (r *TracedRepository) SetActor(ctx context.Context, actor string) error {
+	ctx, span := r.start(ctx, "SetActor")
+	err := r.repo.SetActor(ctx, actor)
+	finish(span, err)
+
+	return err
+}
+
+func (r *TracedRepository) SampleHistory(ctx context.Context, id string) ([]SampleChange, error) {
+	ctx, span := r.start(ctx, "SampleHistory")
+	changes, err := r.repo.SampleHistory(ctx, id)
+	finish(span, err)
+
+	return changes, err
+}
+
+func (r *TracedRepository) FindSampleAsOf(ctx context.Context, id string, t time.Time, opts ...FindOption) (Sample, error) {
+	ctx, span := r.start(ctx, "FindSampleAsOf")
+	sample, err := r.repo.FindSampleAsOf(ctx, id, t, opts...)
+	finish(span, err)
+
+	return sample, err
+}
+
+func (r *TracedRepository) AppendEvent(ctx context.Context, topic string, key string, payload json.RawMessage) (Event, error) {
+	ctx, span := r.start(ctx, "AppendEvent")
+	event, err := r.repo.AppendEvent(ctx, topic, key, payload)
+	finish(span, err)
+
+	return event, err
+}
+
+func (r *TracedRepository) AdvisoryXactLock(ctx context.Context, key string) error {
+	ctx, span := r.start(ctx, "AdvisoryXactLock")
+	err := r.repo.AdvisoryXactLock(ctx, key)
+	finish(span, err)
+
+	return err
+}
+
+func (r *TracedRepository) TryAdvisoryXactLock(ctx context.Context, key string) error {
+	ctx, span := r.start(ctx, "TryAdvisoryXactLock")
+	err := r.repo.TryAdvisoryXactLock(ctx, key)
+	finish(span, err)
+
+	return err
+}
+
+// WithTx records a span covering the whole transaction and hands fn a
+// TracedRepository bound to it, so the calls fn makes get method spans too.
+func (r *TracedRepository) WithTx(ctx context.Context, fn func(*TracedRepository) error) error {
+	ctx, span := r.start(ctx, "WithTx")
+	err := r.repo.WithTx(ctx, func(tx Repo) error {
+		return fn(&TracedRepository{repo: tx, tracer: r.tracer})
+	})
+	finish(span, err)
+
+	return err
+}
+
+// parseStatement extracts the operation and the first table a statement
+// touches. It only understands the shapes of statements this package issues.
+func parseStatement(sql string) (string, string) {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "", ""
+	}
+
+	operation := strings.ToUpper(fields[0])
+
+	for i, field := range fields[:len(fields)-1] {
+		switch strings.ToUpper(field) {
+		case "INTO", "UPDATE", "FROM":
+			return operation, strings.Trim(fields[i+1], `"(),;`)
+		}
+	}
+
+	return operation, ""
+}
+
+// RegisterPoolMetrics reports the connection statistics of pool as
+// observable instruments of mp. The returned registration stops reporting
+// when unregistered.
+func RegisterPoolMetrics(pool *pgxpool.Pool, mp metric.MeterProvider) (metric.Registration, error) {
+	meter := mp.Meter(instrumentationName)
+
+	count, err := meter.Int64ObservableUpDownCounter(
+		"db.client.connection.count",
+		metric.WithDescription("Number of connections that are currently in the state described by the state attribute."),
+		metric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	maxConns, err := meter.Int64ObservableUpDownCounter(
+		"db.client.connection.max",
+		metric.WithDescription("Maximum number of open connections allowed."),
+		metric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	acquires, err := meter.Int64ObservableCounter(
+		"db.client.connection.acquires",
+		metric.WithDescription("Number of successful connection acquires, by whether the pool had to wait."),
+		metric.WithUnit("{acquire}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	canceled, err := meter.Int64ObservableCounter(
+		"db.client.connection.canceled_acquires",
+		metric.WithDescription("Number of connection acquires canceled by their context."),
+		metric.WithUnit("{acquire}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	waitTime, err := meter.Float64ObservableCounter(
+		"db.client.connection.wait_time",
+		metric.WithDescription("Total time spent acquiring connections."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	system := metric.WithAttributes(semconv.DBSystemNamePostgreSQL)
+
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stat := pool.Stat()
+
+		o.ObserveInt64(count, int64(stat.IdleConns()), metric.WithAttributes(semconv.DBSystemNamePostgreSQL, semconv.DBClientConnectionStateIdle))
+		o.ObserveInt64(count, int64(stat.AcquiredConns()), metric.WithAttributes(semconv.DBSystemNamePostgreSQL, semconv.DBClientConnectionStateUsed))
+		o.ObserveInt64(maxConns, int64(stat.MaxConns()), system)
+		o.ObserveInt64(acquires, stat.AcquireCount()-stat.EmptyAcquireCount(), metric.WithAttributes(semconv.DBSystemNamePostgreSQL, attribute.Bool("wait", false)))
+		o.ObserveInt64(acquires, stat.EmptyAcquireCount(), metric.WithAttributes(semconv.DBSystemNamePostgreSQL, attribute.Bool("wait", true)))
+		o.ObserveInt64(canceled, stat.CanceledAcquireCount(), system)
+		o.ObserveFloat64(waitTime, stat.AcquireDuration().Seconds(), system)
+
+		return nil
+	}, count, maxConns, acquires, canceled, waitTime)
+}
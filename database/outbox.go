@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+// eventColumns is the column list scanEvent expects, in its order.
+const eventColumns = `id, topic, key, payload, created_at, delivered_at`
+
+const (
+	appendEventQuery = `INSERT INTO outbox (topic, key, payload) VALUES ($1, $2, $3) RETURNING ` + eventColumns
+	claimEventsQuery = `SELECT ` + eventColumns + ` FROM outbox WHERE delivered_at IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED`
+)
+
+// Event is a domain event stored in the outbox. Key identifies the aggregate
+// the event is about, such as a sample ID.
+type Event struct {
+	ID          int64
+	Topic       string
+	Key         string
+	Payload     json.RawMessage
+	CreatedAt   time.Time
+	DeliveredAt *time.Time
+}
+
+// Publisher delivers outbox events to a broker. Publish may be called again
+// for an event it already published, so consumers must tolerate duplicates.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// AppendEvent writes an event to the outbox. Called on a Transaction, the
+// event is committed or rolled back together with the rest of it.
+func (r Repo) AppendEvent(ctx context.Context, topic string, key string, payload json.RawMessage) (Event, error) {
+	event, err := scanEvent(r.dbtx.QueryRow(ctx, appendEventQuery, topic, key, payload))
+	if err != nil {
+		return Event{}, wrapError("AppendEvent", err)
+	}
+
+	return event, nil
+}
+
+func (r Repo) claimEvents(ctx context.Context, limit int) ([]Event, error) {
+	rows, err := r.dbtx.Query(ctx, claimEventsQuery, limit)
+	if err != nil {
+		return nil, wrapError("Process", err)
+	}
+	defer rows.Close()
+
+	events, err := collectEvents(rows)
+	if err != nil {
+		return nil, wrapError("Process", err)
+	}
+
+	return events, nil
+}
+
+func scanEvent(row rowScanner) (Event, error) {
+	var event Event
+	if err := row.Scan(&event.ID, &event.Topic, &event.Key, &event.Payload, &event.CreatedAt, &event.DeliveredAt); err != nil {
+		return Event{}, err
+	}
+
+	return event, nil
+}
+
+func collectEvents(rows rowsScanner) ([]Event, error) {
+	var events []Event
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (r Repo) markDelivered(ctx context.Context, ids []int64) error {
+	if _, err := r.dbtx.Exec(ctx, `UPDATE outbox SET delivered_at = CURRENT_TIMESTAMP WHERE id = ANY($1)`, ids); err != nil {
+		return wrapError("Process", err)
+	}
+
+	return nil
+}
+
+// DefaultPublishTimeout bounds how long a batch may spend in Publish.
+const DefaultPublishTimeout = 30 * time.Second
+
+// Relay moves events from the outbox to a Publisher. Each batch is claimed
+// with FOR UPDATE SKIP LOCKED, so several relays can share one outbox, and
+// marked delivered in the transaction that claimed it. If the relay dies or
+// Publish fails part way through a batch, the whole batch is published again:
+// delivery is at least once, and in order only with a single relay.
+//
+// The claiming transaction, and its row locks, stay open while the batch is
+// published, so a slow broker is cut off by the publish timeout.
+type Relay struct {
+	repo           Repo
+	publisher      Publisher
+	batchSize      int
+	publishTimeout time.Duration
+	onError        func(error)
+}
+
+type RelayOption func(*Relay)
+
+// WithPublishTimeout bounds the time one batch may spend in Publish. When it
+// expires the batch is rolled back and published again later.
+func WithPublishTimeout(d time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.publishTimeout = d
+	}
+}
+
+// WithRelayErrorHandler makes Run call fn with every error from a batch
+// instead of logging it with slog.Default.
+func WithRelayErrorHandler(fn func(error)) RelayOption {
+	return func(r *Relay) {
+		r.onError = fn
+	}
+}
+
+func NewRelay(db DBTX, publisher Publisher, batchSize int, opts ...RelayOption) (*Relay, error) {
+	repo, err := NewRepo(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if batchSize <= 0 {
+		batchSize = DefaultPageSize
+	}
+
+	r := &Relay{
+		repo:           repo,
+		publisher:      publisher,
+		batchSize:      batchSize,
+		publishTimeout: DefaultPublishTimeout,
+		onError: func(err error) {
+			slog.Error("failed to relay outbox events", slog.Any("error", err))
+		},
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r, nil
+}
+
+// Process publishes one batch of pending events and returns how many were
+// delivered.
+func (r *Relay) Process(ctx context.Context) (int, error) {
+	var delivered int
+
+	if err := r.repo.WithTx(ctx, func(tx Repo) error {
+		events, err := tx.claimEvents(ctx, r.batchSize)
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		if err := r.publish(ctx, events); err != nil {
+			return err
+		}
+
+		ids := make([]int64, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+
+		if err := tx.markDelivered(ctx, ids); err != nil {
+			return err
+		}
+
+		delivered = len(events)
+
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	return delivered, nil
+}
+
+func (r *Relay) publish(ctx context.Context, events []Event) error {
+	ctx, cancel := context.WithTimeout(ctx, r.publishTimeout)
+	defer cancel()
+
+	for _, event := range events {
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run processes batches until the outbox is drained, then polls it every
+// interval until ctx is done. A failed batch is reported to the error handler
+// and retried on the next poll.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := r.Process(ctx)
+			if err != nil && ctx.Err() == nil {
+				r.onError(err)
+			}
+
+			if err != nil || n < r.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type SortOrder int
+
+const (
+	SortAsc SortOrder = iota
+	SortDesc
+)
+
+// ListSamplesParams selects a page of samples ordered by (created_at, id).
+// Cursor is the NextCursor of the previous page and must be used with the same
+// filters and order it was issued for. Soft-deleted samples are skipped unless
+// IncludeDeleted is set.
+type ListSamplesParams struct {
+	PageSize       int
+	Cursor         string
+	NamePrefix     string
+	NameContains   string
+	Order          SortOrder
+	IncludeDeleted bool
+}
+
+type SamplePage struct {
+	Samples []Sample
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
+
+// listSamplesQuery builds the statement for a page of samples. It selects one
+// row more than the page size to learn whether another page follows.
+func listSamplesQuery(params ListSamplesParams) (string, []any, error) {
+	var (
+		where []string
+		args  []any
+	)
+
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if !params.IncludeDeleted {
+		where = append(where, "deleted_at IS NULL")
+	}
+
+	if params.NamePrefix != "" {
+		where = append(where, "starts_with(name, "+arg(params.NamePrefix)+")")
+	}
+
+	if params.NameContains != "" {
+		where = append(where, "strpos(name, "+arg(params.NameContains)+") > 0")
+	}
+
+	order, op := "ASC", ">"
+	if params.Order == SortDesc {
+		order, op = "DESC", "<"
+	}
+
+	if params.Cursor != "" {
+		c, err := decodeCursor(params.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+
+		where = append(where, "(created_at, id) "+op+" ("+arg(c.CreatedAt)+", "+arg(c.ID)+")")
+	}
+
+	query := `SELECT ` + sampleColumns + ` FROM samples`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+
+	query += ` ORDER BY created_at ` + order + `, id ` + order + ` LIMIT ` + arg(params.pageSize()+1)
+
+	return query, args, nil
+}
+
+// newSamplePage trims the extra row fetched by listSamplesQuery and turns it
+// into the cursor for the next page.
+func newSamplePage(samples []Sample, size int) SamplePage {
+	var page SamplePage
+
+	if len(samples) > size {
+		samples = samples[:size]
+		page.NextCursor = encodeCursor(samples[size-1])
+	}
+
+	page.Samples = samples
+
+	return page
+}
+
+func (p ListSamplesParams) pageSize() int {
+	switch {
+	case p.PageSize <= 0:
+		return DefaultPageSize
+	case p.PageSize > MaxPageSize:
+		return MaxPageSize
+	default:
+		return p.PageSize
+	}
+}
+
+type cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func encodeCursor(sample Sample) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(sample.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + sample.ID))
+}
+
+func decodeCursor(s string) (cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), ",")
+	if !ok || id == "" {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	return cursor{CreatedAt: t, ID: id}, nil
+}
@@ -0,0 +1,416 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// sampleColumns is the column list scanSample expects, in its order.
+const sampleColumns = `id, name, created_at, updated_at, version, deleted_at`
+
+const (
+	insertSampleQuery           = `INSERT INTO samples (name) VALUES ($1) RETURNING ` + sampleColumns
+	insertSampleIdempotentQuery = `INSERT INTO samples (name, idempotency_key) VALUES ($1, $2) ON CONFLICT (idempotency_key) DO NOTHING RETURNING ` + sampleColumns
+	findSampleByKeyQuery        = `SELECT ` + sampleColumns + ` FROM samples WHERE idempotency_key = $1`
+	upsertSampleByNameQuery     = `INSERT INTO samples (name) VALUES ($1) ON CONFLICT (name) WHERE deleted_at IS NULL DO UPDATE SET name = EXCLUDED.name RETURNING ` + sampleColumns
+	findSamplesByIDsQuery       = `SELECT ` + sampleColumns + ` FROM samples WHERE id = ANY($1::uuid[])`
+	updateSampleQuery           = `UPDATE samples SET name = $1 WHERE id = $2 AND deleted_at IS NULL`
+	updateSampleIfVersionQuery  = `UPDATE samples SET name = $1 WHERE id = $2 AND version = $3 AND deleted_at IS NULL RETURNING ` + sampleColumns
+	sampleExistsQuery           = `SELECT EXISTS (SELECT 1 FROM samples WHERE id = $1 AND deleted_at IS NULL)`
+	samplesQuery                = `SELECT ` + sampleColumns + ` FROM samples WHERE deleted_at IS NULL ORDER BY created_at, id`
+	deleteSamplesQuery          = `DELETE FROM samples`
+	deleteSampleQuery           = `UPDATE samples SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL`
+	restoreSampleQuery          = `UPDATE samples SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`
+	purgeDeletedBeforeQuery     = `DELETE FROM samples WHERE deleted_at < $1`
+)
+
+type Repo struct {
+	dbtx DBTX
+}
+
+func NewRepo(dbtx DBTX) (Repo, error) {
+	return Repo{dbtx: dbtx}, nil
+}
+
+func (r Repo) InsertSample(ctx context.Context, name string) (Sample, error) {
+	sample, err := scanSample(r.dbtx.QueryRow(ctx, insertSampleQuery, name))
+	if err != nil {
+		return Sample{}, wrapError("InsertSample", err)
+	}
+
+	return sample, nil
+}
+
+// InsertSampleIdempotent inserts a sample tagged with a client-supplied key.
+// Retrying with the same key returns the sample created by the first call
+// instead of inserting a duplicate, even if name differs.
+func (r Repo) InsertSampleIdempotent(ctx context.Context, key string, name string) (Sample, error) {
+	return insertSampleIdempotent(func() (Sample, error) {
+		return scanSample(r.dbtx.QueryRow(ctx, insertSampleIdempotentQuery, name, key))
+	}, func() (Sample, error) {
+		return scanSample(r.dbtx.QueryRow(ctx, findSampleByKeyQuery, key))
+	})
+}
+
+// insertSampleIdempotent runs insert and, when the key is already taken, reads
+// the winner with find. ON CONFLICT only covers the idempotency key, so a
+// concurrent retry that loses the race on the live-name index is reported as a
+// unique violation on samples_name_key; that too falls back to find, and the
+// violation is returned only when no sample has the key.
+func insertSampleIdempotent(insert, find func() (Sample, error)) (Sample, error) {
+	sample, err := insert()
+	if err == nil {
+		return sample, nil
+	}
+
+	err = wrapError("InsertSampleIdempotent", err)
+
+	var e *Error
+	nameTaken := errors.As(err, &e) && e.Code == CodeUniqueViolation && e.Constraint == "samples_name_key"
+
+	if !errors.Is(err, ErrNotFound) && !nameTaken {
+		return Sample{}, err
+	}
+
+	// Read the winner in a new statement so that, under READ COMMITTED, a row
+	// committed while the insert waited is visible.
+	sample, findErr := find()
+	if findErr == nil {
+		return sample, nil
+	}
+
+	findErr = wrapError("InsertSampleIdempotent", findErr)
+
+	// No sample has the key, so the name belongs to another sample.
+	if nameTaken && errors.Is(findErr, ErrNotFound) {
+		return Sample{}, err
+	}
+
+	return Sample{}, findErr
+}
+
+// UpsertSampleByName returns the live sample called name, creating it if
+// needed. An existing sample is touched, which bumps its updated_at and
+// version.
+func (r Repo) UpsertSampleByName(ctx context.Context, name string) (Sample, error) {
+	sample, err := scanSample(r.dbtx.QueryRow(ctx, upsertSampleByNameQuery, name))
+	if err != nil {
+		return Sample{}, wrapError("UpsertSampleByName", err)
+	}
+
+	return sample, nil
+}
+
+// InsertSamples inserts names with a single COPY and returns the samples in
+// the same order as names. IDs are generated client-side so each returned
+// sample lines up with its input.
+func (r Repo) InsertSamples(ctx context.Context, names []string) ([]Sample, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, len(names))
+	for i := range names {
+		ids[i] = uuid.NewString()
+	}
+
+	if _, err := r.dbtx.CopyFrom(ctx, pgx.Identifier{"samples"}, []string{"id", "name"}, pgx.CopyFromSlice(len(names), func(i int) ([]any, error) {
+		return []any{ids[i], names[i]}, nil
+	})); err != nil {
+		return nil, wrapError("InsertSamples", err)
+	}
+
+	rows, err := r.dbtx.Query(ctx, findSamplesByIDsQuery, ids)
+	if err != nil {
+		return nil, wrapError("InsertSamples", err)
+	}
+	defer rows.Close()
+
+	inserted, err := collectSamples(rows)
+	if err != nil {
+		return nil, wrapError("InsertSamples", err)
+	}
+
+	return orderSamples(inserted, ids), nil
+}
+
+// orderSamples returns samples in the order of ids.
+func orderSamples(samples []Sample, ids []string) []Sample {
+	byID := make(map[string]Sample, len(samples))
+	for _, sample := range samples {
+		byID[sample.ID] = sample
+	}
+
+	ordered := make([]Sample, len(ids))
+	for i, id := range ids {
+		ordered[i] = byID[id]
+	}
+
+	return ordered
+}
+
+// CopySamples streams rows from src into samples with COPY. Each row holds a
+// single value, the sample name.
+func (r Repo) CopySamples(ctx context.Context, src pgx.CopyFromSource) (int64, error) {
+	n, err := r.dbtx.CopyFrom(ctx, pgx.Identifier{"samples"}, []string{"name"}, src)
+	if err != nil {
+		return n, wrapError("CopySamples", err)
+	}
+
+	return n, nil
+}
+
+func (r Repo) UpdateSample(ctx context.Context, id string, name string) error {
+	tag, err := r.dbtx.Exec(ctx, updateSampleQuery, name, id)
+	if err != nil {
+		return wrapError("UpdateSample", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return wrapError("UpdateSample", ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdateSampleIfVersion renames the sample only if it is still at
+// expectedVersion and returns it with its new version. It returns ErrConflict
+// when another update got there first.
+func (r Repo) UpdateSampleIfVersion(ctx context.Context, id string, name string, expectedVersion int64) (Sample, error) {
+	sample, err := scanSample(r.dbtx.QueryRow(ctx, updateSampleIfVersionQuery, name, id, expectedVersion))
+	if err == nil {
+		return sample, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Sample{}, wrapError("UpdateSampleIfVersion", err)
+	}
+
+	var exists bool
+
+	if err := r.dbtx.QueryRow(ctx, sampleExistsQuery, id).Scan(&exists); err != nil {
+		return Sample{}, wrapError("UpdateSampleIfVersion", err)
+	}
+
+	if !exists {
+		return Sample{}, wrapError("UpdateSampleIfVersion", ErrNotFound)
+	}
+
+	return Sample{}, wrapError("UpdateSampleIfVersion", ErrConflict)
+}
+
+type FindOption func(*findOptions)
+
+type findOptions struct {
+	includeDeleted bool
+}
+
+// IncludeDeleted makes a lookup return soft-deleted samples too.
+func IncludeDeleted() FindOption {
+	return func(o *findOptions) {
+		o.includeDeleted = true
+	}
+}
+
+func (r Repo) FindSampleByID(ctx context.Context, id string, opts ...FindOption) (Sample, error) {
+	return r.findSample(ctx, "FindSampleByID", id, "", opts)
+}
+
+func (r Repo) findSample(ctx context.Context, op string, id string, lock string, opts []FindOption) (Sample, error) {
+	sample, err := scanSample(r.dbtx.QueryRow(ctx, findSampleQuery(lock, opts), id))
+	if err != nil {
+		return Sample{}, wrapError(op, err)
+	}
+
+	return sample, nil
+}
+
+// findSampleQuery builds the statement that reads one sample by id, taking
+// the row lock given by lock, if any.
+func findSampleQuery(lock string, opts []FindOption) string {
+	var o findOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	query := `SELECT ` + sampleColumns + ` FROM samples WHERE id = $1`
+	if !o.includeDeleted {
+		query += ` AND deleted_at IS NULL`
+	}
+
+	if lock != "" {
+		query += ` ` + lock
+	}
+
+	return query
+}
+
+// rowScanner is the part of pgx.Row and *sql.Row that scanSample uses.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSample(row rowScanner) (Sample, error) {
+	var sample Sample
+	if err := row.Scan(&sample.ID, &sample.Name, &sample.CreatedAt, &sample.UpdatedAt, &sample.Version, &sample.DeletedAt); err != nil {
+		return Sample{}, err
+	}
+
+	return sample, nil
+}
+
+// rowsScanner is the part of pgx.Rows and *sql.Rows that the collect helpers
+// use, so that Repo and SQLRepo read results the same way.
+type rowsScanner interface {
+	rowScanner
+	Next() bool
+	Err() error
+}
+
+func collectSamples(rows rowsScanner) ([]Sample, error) {
+	var samples []Sample
+	for rows.Next() {
+		sample, err := scanSample(rows)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+func (r Repo) ListSamples(ctx context.Context, params ListSamplesParams) (SamplePage, error) {
+	query, args, err := listSamplesQuery(params)
+	if err != nil {
+		return SamplePage{}, wrapError("ListSamples", err)
+	}
+
+	rows, err := r.dbtx.Query(ctx, query, args...)
+	if err != nil {
+		return SamplePage{}, wrapError("ListSamples", err)
+	}
+	defer rows.Close()
+
+	samples, err := collectSamples(rows)
+	if err != nil {
+		return SamplePage{}, wrapError("ListSamples", err)
+	}
+
+	return newSamplePage(samples, params.pageSize()), nil
+}
+
+// Samples streams every sample that is not soft-deleted, ordered by (created_at, id) without loading
+// them into memory. Breaking out of the loop closes the rows and releases the
+// connection.
+func (r Repo) Samples(ctx context.Context) iter.Seq2[Sample, error] {
+	return func(yield func(Sample, error) bool) {
+		rows, err := r.dbtx.Query(ctx, samplesQuery)
+		if err != nil {
+			yield(Sample{}, wrapError("Samples", err))
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			sample, err := scanSample(rows)
+			if err != nil {
+				yield(Sample{}, wrapError("Samples", err))
+				return
+			}
+
+			if !yield(sample, nil) {
+				return
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			yield(Sample{}, wrapError("Samples", err))
+		}
+	}
+}
+
+// DeleteSamples removes every sample for good, including soft-deleted ones.
+func (r Repo) DeleteSamples(ctx context.Context) error {
+	if _, err := r.dbtx.Exec(ctx, deleteSamplesQuery); err != nil {
+		return wrapError("DeleteSamples", err)
+	}
+
+	return nil
+}
+
+// DeleteSample soft-deletes the sample. It stays in the table, hidden from
+// reads, until it is restored or purged.
+func (r Repo) DeleteSample(ctx context.Context, id string) error {
+	tag, err := r.dbtx.Exec(ctx, deleteSampleQuery, id)
+	if err != nil {
+		return wrapError("DeleteSample", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return wrapError("DeleteSample", ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r Repo) RestoreSample(ctx context.Context, id string) error {
+	tag, err := r.dbtx.Exec(ctx, restoreSampleQuery, id)
+	if err != nil {
+		return wrapError("RestoreSample", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return wrapError("RestoreSample", ErrNotFound)
+	}
+
+	return nil
+}
+
+// PurgeDeletedBefore permanently removes samples soft-deleted before t and
+// reports how many were removed.
+func (r Repo) PurgeDeletedBefore(ctx context.Context, t time.Time) (int64, error) {
+	tag, err := r.dbtx.Exec(ctx, purgeDeletedBeforeQuery, t)
+	if err != nil {
+		return 0, wrapError("PurgeDeletedBefore", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// WithTx runs fn in a transaction and commits it if fn returns nil. When the
+// Repo is already bound to a transaction, the inner scope is a SAVEPOINT:
+// returning an error rolls back only the work done since the savepoint and
+// leaves the outer transaction usable.
+func (r Repo) WithTx(ctx context.Context, fn func(Repo) error) error {
+	tx, err := r.dbtx.Begin(ctx)
+	if err != nil {
+		return wrapError("WithTx", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if err := setActor(ctx, tx); err != nil {
+		return wrapError("WithTx", err)
+	}
+
+	if err := fn(Repo{dbtx: tx}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return wrapError("WithTx", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SampleRepository is the sample API shared by Database, Transaction, Router
+// and their database/sql counterpart SQLRepo. repotest.Run checks that an
+// implementation behaves like the others.
+type SampleRepository interface {
+	InsertSample(ctx context.Context, name string) (Sample, error)
+	InsertSampleIdempotent(ctx context.Context, key string, name string) (Sample, error)
+	UpsertSampleByName(ctx context.Context, name string) (Sample, error)
+	InsertSamples(ctx context.Context, names []string) ([]Sample, error)
+	UpdateSample(ctx context.Context, id string, name string) error
+	UpdateSampleIfVersion(ctx context.Context, id string, name string, expectedVersion int64) (Sample, error)
+	FindSampleByID(ctx context.Context, id string, opts ...FindOption) (Sample, error)
+	ListSamples(ctx context.Context, params ListSamplesParams) (SamplePage, error)
+	Samples(ctx context.Context) iter.Seq2[Sample, error]
+	DeleteSamples(ctx context.Context) error
+	DeleteSample(ctx context.Context, id string) error
+	RestoreSample(ctx context.Context, id string) error
+	PurgeDeletedBefore(ctx context.Context, t time.Time) (int64, error)
+}
+
+// Repository is the whole pgx API of Database, Transaction and Router. It is
+// what TracedRepository wraps.
+type Repository interface {
+	SampleRepository
+	CopySamples(ctx context.Context, src pgx.CopyFromSource) (int64, error)
+	SendBatch(ctx context.Context, b *Batch) error
+	FindSampleForUpdate(ctx context.Context, id string, opts ...LockOption) (Sample, error)
+	FindSampleForShare(ctx context.Context, id string, opts ...LockOption) (Sample, error)
+	ClaimSamples(ctx context.Context, limit int, opts ...LockOption) ([]Sample, error)
+	SetActor(ctx context.Context, actor string) error
+	SampleHistory(ctx context.Context, id string) ([]SampleChange, error)
+	FindSampleAsOf(ctx context.Context, id string, t time.Time, opts ...FindOption) (Sample, error)
+	AppendEvent(ctx context.Context, topic string, key string, payload json.RawMessage) (Event, error)
+	AdvisoryXactLock(ctx context.Context, key string) error
+	TryAdvisoryXactLock(ctx context.Context, key string) error
+	WithTx(ctx context.Context, fn func(Repo) error) error
+}
+
+var (
+	_ Repository       = Repo{}
+	_ Repository       = (*Router)(nil)
+	_ SampleRepository = Repo{}
+	_ SampleRepository = (*Router)(nil)
+	_ SampleRepository = SQLRepo{}
+)
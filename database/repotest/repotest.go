@@ -0,0 +1,455 @@
+// Package repotest checks that a database.SampleRepository behaves like the
+// reference implementation, so every backend can prove it with one call.
+package repotest
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/otakakot/sample-go-postgresql-test/database"
+)
+
+// Factory returns the repository under test. It is called once per subtest,
+// and once per worker in the concurrency subtest, so it may hand out a fresh
+// transaction each time and roll it back in t.Cleanup. Repositories from
+// separate calls must not share a transaction, and up to eight of them are
+// used at once.
+type Factory func(t *testing.T) database.SampleRepository
+
+// Run checks CRUD, not-found, ordering, concurrency and error mapping
+// behavior. The subtests write to the samples table of the schema in
+// schema/, and DeleteSamples removes every row of it.
+func Run(t *testing.T, factory Factory) {
+	t.Helper()
+
+	t.Run("CRUD", func(t *testing.T) {
+		testCRUD(t, factory(t))
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		testNotFound(t, factory(t))
+	})
+
+	t.Run("Ordering", func(t *testing.T) {
+		testOrdering(t, factory(t))
+	})
+
+	t.Run("Concurrency", func(t *testing.T) {
+		testConcurrency(t, factory)
+	})
+
+	t.Run("ErrorMapping", func(t *testing.T) {
+		testErrorMapping(t, factory(t))
+	})
+
+	t.Run("DeleteSamples", func(t *testing.T) {
+		testDeleteSamples(t, factory(t))
+	})
+}
+
+func name(prefix string) string {
+	return prefix + uuid.NewString()
+}
+
+// expectOp checks that err is a *database.Error for op wrapping target.
+func expectOp(t *testing.T, err error, op string, target error) {
+	t.Helper()
+
+	if !errors.Is(err, target) {
+		t.Errorf("%s: expected %v, got %v", op, target, err)
+		return
+	}
+
+	var dbErr *database.Error
+	if !errors.As(err, &dbErr) || dbErr.Op != op {
+		t.Errorf("%s: expected *database.Error with Op %q, got %#v", op, op, err)
+	}
+}
+
+func testCRUD(t *testing.T, repo database.SampleRepository) {
+	original := name("crud-")
+
+	sample, err := repo.InsertSample(t.Context(), original)
+	if err != nil {
+		t.Fatalf("failed to insert sample: %v", err)
+	}
+
+	if sample.ID == "" || sample.Name != original || sample.Version != 1 || sample.CreatedAt.IsZero() || sample.UpdatedAt.IsZero() || sample.DeletedAt != nil {
+		t.Fatalf("unexpected inserted sample: %+v", sample)
+	}
+
+	found, err := repo.FindSampleByID(t.Context(), sample.ID)
+	if err != nil {
+		t.Fatalf("failed to find sample: %v", err)
+	}
+
+	if found.ID != sample.ID || found.Name != original || !found.CreatedAt.Equal(sample.CreatedAt) || found.Version != 1 {
+		t.Fatalf("found sample does not match inserted sample: got %+v, want %+v", found, sample)
+	}
+
+	renamed := name("crud-")
+
+	if err := repo.UpdateSample(t.Context(), sample.ID, renamed); err != nil {
+		t.Fatalf("failed to update sample: %v", err)
+	}
+
+	found, err = repo.FindSampleByID(t.Context(), sample.ID)
+	if err != nil {
+		t.Fatalf("failed to find updated sample: %v", err)
+	}
+
+	if found.Name != renamed || found.Version != 2 {
+		t.Fatalf("unexpected updated sample: %+v", found)
+	}
+
+	if !found.CreatedAt.Equal(sample.CreatedAt) {
+		t.Fatalf("created_at changed on update: got %v, want %v", found.CreatedAt, sample.CreatedAt)
+	}
+
+	if found.UpdatedAt.Before(sample.UpdatedAt) {
+		t.Fatalf("updated_at went back on update: got %v, want not before %v", found.UpdatedAt, sample.UpdatedAt)
+	}
+
+	versioned, err := repo.UpdateSampleIfVersion(t.Context(), sample.ID, original, 2)
+	if err != nil {
+		t.Fatalf("failed to update sample at version 2: %v", err)
+	}
+
+	if versioned.Name != original || versioned.Version != 3 {
+		t.Fatalf("unexpected versioned sample: %+v", versioned)
+	}
+
+	upserted, err := repo.UpsertSampleByName(t.Context(), original)
+	if err != nil {
+		t.Fatalf("failed to upsert sample: %v", err)
+	}
+
+	if upserted.ID != sample.ID {
+		t.Fatalf("upsert created a duplicate: got %s, want %s", upserted.ID, sample.ID)
+	}
+
+	key := uuid.NewString()
+
+	first, err := repo.InsertSampleIdempotent(t.Context(), key, name("crud-"))
+	if err != nil {
+		t.Fatalf("failed to insert idempotent sample: %v", err)
+	}
+
+	second, err := repo.InsertSampleIdempotent(t.Context(), key, name("crud-"))
+	if err != nil {
+		t.Fatalf("failed to retry idempotent sample: %v", err)
+	}
+
+	if first.ID != second.ID || first.Name != second.Name {
+		t.Fatalf("retry with the same key inserted again: got %+v, want %+v", second, first)
+	}
+
+	names := []string{name("crud-"), name("crud-"), name("crud-")}
+
+	inserted, err := repo.InsertSamples(t.Context(), names)
+	if err != nil {
+		t.Fatalf("failed to insert samples: %v", err)
+	}
+
+	if len(inserted) != len(names) {
+		t.Fatalf("unexpected inserted samples: got %d, want %d", len(inserted), len(names))
+	}
+
+	for i, s := range inserted {
+		if s.ID == "" || s.Name != names[i] {
+			t.Fatalf("unexpected inserted sample %d: got %+v, want name %s", i, s, names[i])
+		}
+	}
+
+	if err := repo.DeleteSample(t.Context(), sample.ID); err != nil {
+		t.Fatalf("failed to delete sample: %v", err)
+	}
+
+	if _, err := repo.FindSampleByID(t.Context(), sample.ID); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for soft-deleted sample, got %v", err)
+	}
+
+	deleted, err := repo.FindSampleByID(t.Context(), sample.ID, database.IncludeDeleted())
+	if err != nil {
+		t.Fatalf("failed to find soft-deleted sample: %v", err)
+	}
+
+	if deleted.DeletedAt == nil {
+		t.Fatalf("expected deleted_at to be set: %+v", deleted)
+	}
+
+	if err := repo.RestoreSample(t.Context(), sample.ID); err != nil {
+		t.Fatalf("failed to restore sample: %v", err)
+	}
+
+	if restored, err := repo.FindSampleByID(t.Context(), sample.ID); err != nil || restored.DeletedAt != nil {
+		t.Fatalf("expected restored sample, got %+v, %v", restored, err)
+	}
+
+	if err := repo.DeleteSample(t.Context(), sample.ID); err != nil {
+		t.Fatalf("failed to delete sample again: %v", err)
+	}
+
+	purged, err := repo.PurgeDeletedBefore(t.Context(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to purge deleted samples: %v", err)
+	}
+
+	if purged < 1 {
+		t.Fatalf("unexpected purged count: got %d, want at least 1", purged)
+	}
+
+	if _, err := repo.FindSampleByID(t.Context(), sample.ID, database.IncludeDeleted()); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for purged sample, got %v", err)
+	}
+}
+
+func testNotFound(t *testing.T, repo database.SampleRepository) {
+	missing := uuid.NewString()
+
+	_, err := repo.FindSampleByID(t.Context(), missing)
+	expectOp(t, err, "FindSampleByID", database.ErrNotFound)
+
+	_, err = repo.FindSampleByID(t.Context(), missing, database.IncludeDeleted())
+	expectOp(t, err, "FindSampleByID", database.ErrNotFound)
+
+	expectOp(t, repo.UpdateSample(t.Context(), missing, name("missing-")), "UpdateSample", database.ErrNotFound)
+
+	_, err = repo.UpdateSampleIfVersion(t.Context(), missing, name("missing-"), 1)
+	expectOp(t, err, "UpdateSampleIfVersion", database.ErrNotFound)
+
+	expectOp(t, repo.DeleteSample(t.Context(), missing), "DeleteSample", database.ErrNotFound)
+
+	expectOp(t, repo.RestoreSample(t.Context(), missing), "RestoreSample", database.ErrNotFound)
+
+	live, err := repo.InsertSample(t.Context(), name("not-found-"))
+	if err != nil {
+		t.Fatalf("failed to insert sample: %v", err)
+	}
+
+	expectOp(t, repo.RestoreSample(t.Context(), live.ID), "RestoreSample", database.ErrNotFound)
+
+	if err := repo.DeleteSample(t.Context(), live.ID); err != nil {
+		t.Fatalf("failed to delete sample: %v", err)
+	}
+
+	expectOp(t, repo.DeleteSample(t.Context(), live.ID), "DeleteSample", database.ErrNotFound)
+	expectOp(t, repo.UpdateSample(t.Context(), live.ID, name("not-found-")), "UpdateSample", database.ErrNotFound)
+
+	_, err = repo.UpdateSampleIfVersion(t.Context(), live.ID, name("not-found-"), 2)
+	expectOp(t, err, "UpdateSampleIfVersion", database.ErrNotFound)
+
+	page, err := repo.ListSamples(t.Context(), database.ListSamplesParams{NamePrefix: name("missing-")})
+	if err != nil {
+		t.Fatalf("failed to list samples: %v", err)
+	}
+
+	if len(page.Samples) != 0 || page.NextCursor != "" {
+		t.Fatalf("expected an empty page, got %+v", page)
+	}
+}
+
+func testOrdering(t *testing.T, repo database.SampleRepository) {
+	prefix := name("ordering-") + "-"
+
+	var samples []database.Sample
+	for range 5 {
+		sample, err := repo.InsertSample(t.Context(), name(prefix))
+		if err != nil {
+			t.Fatalf("failed to insert sample: %v", err)
+		}
+
+		samples = append(samples, sample)
+	}
+
+	// Samples inserted in one transaction share created_at, so the ID breaks
+	// the tie.
+	slices.SortFunc(samples, func(a, b database.Sample) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	want := make([]string, len(samples))
+	for i, sample := range samples {
+		want[i] = sample.ID
+	}
+
+	list := func(order database.SortOrder) []string {
+		var ids []string
+
+		params := database.ListSamplesParams{PageSize: 2, NamePrefix: prefix, Order: order}
+		for {
+			page, err := repo.ListSamples(t.Context(), params)
+			if err != nil {
+				t.Fatalf("failed to list samples: %v", err)
+			}
+
+			if len(page.Samples) > params.PageSize {
+				t.Fatalf("page exceeds page size: got %d, want at most %d", len(page.Samples), params.PageSize)
+			}
+
+			for _, sample := range page.Samples {
+				ids = append(ids, sample.ID)
+			}
+
+			if page.NextCursor == "" {
+				return ids
+			}
+
+			params.Cursor = page.NextCursor
+		}
+	}
+
+	if got := list(database.SortAsc); !slices.Equal(got, want) {
+		t.Errorf("unexpected ascending order: got %v, want %v", got, want)
+	}
+
+	reversed := slices.Clone(want)
+	slices.Reverse(reversed)
+
+	if got := list(database.SortDesc); !slices.Equal(got, reversed) {
+		t.Errorf("unexpected descending order: got %v, want %v", got, reversed)
+	}
+
+	var iterated []string
+	for sample, err := range repo.Samples(t.Context()) {
+		if err != nil {
+			t.Fatalf("failed to iterate samples: %v", err)
+		}
+
+		if slices.Contains(want, sample.ID) {
+			iterated = append(iterated, sample.ID)
+		}
+	}
+
+	if !slices.Equal(iterated, want) {
+		t.Errorf("unexpected iteration order: got %v, want %v", iterated, want)
+	}
+}
+
+func testConcurrency(t *testing.T, factory Factory) {
+	const (
+		workers = 8
+		each    = 5
+	)
+
+	repos := make([]database.SampleRepository, workers)
+	for i := range repos {
+		repos[i] = factory(t)
+	}
+
+	prefix := name("concurrency-") + "-"
+
+	var wg sync.WaitGroup
+	for i, repo := range repos {
+		wg.Go(func() {
+			workerPrefix := prefix + string(rune('a'+i)) + "-"
+
+			var ids []string
+			for range each {
+				sample, err := repo.InsertSample(t.Context(), name(workerPrefix))
+				if err != nil {
+					t.Errorf("worker %d: failed to insert sample: %v", i, err)
+					return
+				}
+
+				ids = append(ids, sample.ID)
+			}
+
+			for _, id := range ids {
+				if err := repo.UpdateSample(t.Context(), id, name(workerPrefix)); err != nil {
+					t.Errorf("worker %d: failed to update sample: %v", i, err)
+					return
+				}
+			}
+
+			page, err := repo.ListSamples(t.Context(), database.ListSamplesParams{NamePrefix: workerPrefix})
+			if err != nil {
+				t.Errorf("worker %d: failed to list samples: %v", i, err)
+				return
+			}
+
+			if len(page.Samples) != each {
+				t.Errorf("worker %d: unexpected samples: got %d, want %d", i, len(page.Samples), each)
+			}
+
+			for _, sample := range page.Samples {
+				if sample.Version != 2 {
+					t.Errorf("worker %d: lost update on %s: version %d", i, sample.ID, sample.Version)
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// testErrorMapping ends with a constraint violation, which aborts the
+// transaction of transactional backends, so nothing may follow it.
+func testErrorMapping(t *testing.T, repo database.SampleRepository) {
+	sample, err := repo.InsertSample(t.Context(), name("error-mapping-"))
+	if err != nil {
+		t.Fatalf("failed to insert sample: %v", err)
+	}
+
+	if _, err := repo.UpdateSampleIfVersion(t.Context(), sample.ID, name("error-mapping-"), 1); err != nil {
+		t.Fatalf("failed to update sample at version 1: %v", err)
+	}
+
+	_, err = repo.UpdateSampleIfVersion(t.Context(), sample.ID, name("error-mapping-"), 1)
+	expectOp(t, err, "UpdateSampleIfVersion", database.ErrConflict)
+
+	if _, err := repo.ListSamples(t.Context(), database.ListSamplesParams{Cursor: "!"}); !errors.Is(err, database.ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+
+	_, err = repo.InsertSample(t.Context(), sample.Name)
+	if err == nil {
+		t.Fatal("expected duplicate name to be rejected")
+	}
+
+	var dbErr *database.Error
+	if !errors.As(err, &dbErr) {
+		t.Fatalf("expected *database.Error, got %T: %v", err, err)
+	}
+
+	if !database.IsUniqueViolation(err) || dbErr.Code != database.CodeUniqueViolation {
+		t.Errorf("expected unique violation, got code %q", dbErr.Code)
+	}
+
+	if dbErr.Op != "InsertSample" || dbErr.Constraint != "samples_name_key" || dbErr.Table != "samples" {
+		t.Errorf("unexpected error details: %+v", dbErr)
+	}
+
+	if errors.Is(err, database.ErrNotFound) || errors.Is(err, database.ErrConflict) {
+		t.Errorf("unique violation matches an unrelated sentinel: %v", err)
+	}
+}
+
+func testDeleteSamples(t *testing.T, repo database.SampleRepository) {
+	sample, err := repo.InsertSample(t.Context(), name("delete-samples-"))
+	if err != nil {
+		t.Fatalf("failed to insert sample: %v", err)
+	}
+
+	if err := repo.DeleteSamples(t.Context()); err != nil {
+		t.Fatalf("failed to delete all samples: %v", err)
+	}
+
+	page, err := repo.ListSamples(t.Context(), database.ListSamplesParams{IncludeDeleted: true})
+	if err != nil {
+		t.Fatalf("failed to list samples: %v", err)
+	}
+
+	if len(page.Samples) != 0 {
+		t.Fatalf("expected no samples, but found %d", len(page.Samples))
+	}
+
+	if _, err := repo.FindSampleByID(t.Context(), sample.ID, database.IncludeDeleted()); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound when finding deleted sample, but got %v", err)
+	}
+}
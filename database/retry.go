@@ -0,0 +1,136 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type txBeginner interface {
+	BeginTx(context.Context, pgx.TxOptions) (pgx.Tx, error)
+}
+
+// RetryPolicy bounds how RunInTx retries transactions that failed with a
+// serialization failure or a deadlock. Zero fields fall back to the values in
+// DefaultRetryPolicy.
+type RetryPolicy struct {
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// OnRetry is called before sleeping for delay ahead of the next attempt.
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	MinBackoff:  10 * time.Millisecond,
+	MaxBackoff:  time.Second,
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	minBackoff := cmp.Or(p.MinBackoff, DefaultRetryPolicy.MinBackoff)
+	maxBackoff := max(cmp.Or(p.MaxBackoff, DefaultRetryPolicy.MaxBackoff), minBackoff)
+
+	ceil := minBackoff << (attempt - 1)
+	if ceil <= 0 || ceil > maxBackoff {
+		ceil = maxBackoff
+	}
+
+	return minBackoff + rand.N(ceil-minBackoff+1)
+}
+
+func retryable(err error) bool {
+	return IsSerializationFailure(err) || IsDeadlock(err)
+}
+
+// RunInTx runs fn in a transaction begun with opts and commits it. When fn or
+// the commit fails with SQLSTATE 40001 or 40P01 the transaction is rolled back
+// and fn is run again in a fresh one, up to policy.MaxAttempts times.
+//
+// If db is already a transaction, fn runs once inside a savepoint and opts are
+// ignored: a serialization failure aborts the outer transaction, so only its
+// owner can retry.
+func RunInTx(
+	ctx context.Context,
+	db DBTX,
+	opts pgx.TxOptions,
+	policy RetryPolicy,
+	fn func(Repo) error,
+) error {
+	if _, ok := db.(txBeginner); !ok {
+		policy.MaxAttempts = 1
+	}
+
+	return policy.retry(ctx, "RunInTx", func() error {
+		return runInTx(ctx, db, opts, fn)
+	})
+}
+
+// retry calls attempt until it succeeds, fails with an error that is not
+// retryable, or p.MaxAttempts is reached, backing off in between.
+func (p RetryPolicy) retry(ctx context.Context, op string, attempt func() error) error {
+	maxAttempts := cmp.Or(p.MaxAttempts, DefaultRetryPolicy.MaxAttempts)
+
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil || !retryable(err) || n >= maxAttempts {
+			return err
+		}
+
+		delay := p.backoff(n)
+
+		if p.OnRetry != nil {
+			p.OnRetry(n, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return wrapError(op, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func runInTx(
+	ctx context.Context,
+	db DBTX,
+	opts pgx.TxOptions,
+	fn func(Repo) error,
+) error {
+	var (
+		tx  pgx.Tx
+		err error
+	)
+
+	if beginner, ok := db.(txBeginner); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		tx, err = db.Begin(ctx)
+	}
+	if err != nil {
+		return wrapError("RunInTx", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if err := setActor(ctx, tx); err != nil {
+		return wrapError("RunInTx", err)
+	}
+
+	if err := fn(Repo{dbtx: tx}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return wrapError("RunInTx", err)
+	}
+
+	return nil
+}
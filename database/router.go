@@ -0,0 +1,224 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type primaryKey struct{}
+
+// ReadYourWrites pins every read made with the returned context to the
+// primary, so a request sees its own writes despite replication lag.
+func ReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func pinnedToPrimary(ctx context.Context) bool {
+	pinned, _ := ctx.Value(primaryKey{}).(bool)
+	return pinned
+}
+
+// reprobeInterval is how long an unhealthy replica is left alone before a
+// read pings it again in the background.
+const reprobeInterval = 5 * time.Second
+
+type replica struct {
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+	downAt  atomic.Int64
+	probing atomic.Bool
+}
+
+func (rep *replica) markDown() {
+	rep.downAt.Store(time.Now().UnixNano())
+	rep.healthy.Store(false)
+}
+
+// reprobe pings an unhealthy replica in the background once reprobeInterval
+// has passed since it was marked down, so it returns to service without the
+// caller running CheckHealth.
+func (rep *replica) reprobe() {
+	if time.Since(time.Unix(0, rep.downAt.Load())) < reprobeInterval || !rep.probing.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer rep.probing.Store(false)
+
+		ctx, cancel := context.WithTimeout(context.Background(), reprobeInterval)
+		defer cancel()
+
+		if rep.pool.Ping(ctx) != nil {
+			rep.markDown()
+			return
+		}
+
+		rep.healthy.Store(true)
+	}()
+}
+
+// Router sends FindSampleByID, ListSamples and Samples to a healthy replica
+// and every other call to the primary. Reads fall back to the primary when no
+// replica is healthy or the chosen replica cannot be reached. A replica marked
+// unhealthy is pinged again lazily by later reads; Run is only needed to find
+// failed replicas before a read does.
+type Router struct {
+	Repo
+
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+func NewRouter(primary *pgxpool.Pool, replicas ...*pgxpool.Pool) (*Router, error) {
+	repo, err := NewRepo(primary)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Router{Repo: repo}
+
+	for _, pool := range replicas {
+		rep := &replica{pool: pool}
+		rep.healthy.Store(true)
+		r.replicas = append(r.replicas, rep)
+	}
+
+	return r, nil
+}
+
+// CheckHealth pings every replica and records which ones may serve reads.
+func (r *Router) CheckHealth(ctx context.Context) {
+	for _, rep := range r.replicas {
+		if rep.pool.Ping(ctx) != nil {
+			rep.markDown()
+			continue
+		}
+
+		rep.healthy.Store(true)
+	}
+}
+
+// Run calls CheckHealth every interval until ctx is done.
+func (r *Router) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.CheckHealth(ctx)
+		}
+	}
+}
+
+func (r *Router) replica(ctx context.Context) *replica {
+	if pinnedToPrimary(ctx) || len(r.replicas) == 0 {
+		return nil
+	}
+
+	start := r.next.Add(1)
+
+	for i := range uint64(len(r.replicas)) {
+		rep := r.replicas[(start+i)%uint64(len(r.replicas))]
+		if rep.healthy.Load() {
+			return rep
+		}
+
+		rep.reprobe()
+	}
+
+	return nil
+}
+
+// unreachable reports whether err came from failing to talk to the replica
+// rather than from the query itself: a network error, a connection exception
+// (class 08) or the server shutting down or starting up (57P01 to 57P03).
+func unreachable(err error) bool {
+	if err == nil || errors.Is(err, ErrNotFound) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "57P01", "57P02", "57P03":
+			return true
+		}
+
+		return strings.HasPrefix(pgErr.Code, "08")
+	}
+
+	return !errors.Is(err, ErrInvalidCursor)
+}
+
+func read[T any](ctx context.Context, r *Router, fn func(Repo) (T, error)) (T, error) {
+	rep := r.replica(ctx)
+	if rep == nil {
+		return fn(r.Repo)
+	}
+
+	v, err := fn(Repo{dbtx: rep.pool})
+	if !unreachable(err) {
+		return v, err
+	}
+
+	rep.markDown()
+
+	return fn(r.Repo)
+}
+
+func (r *Router) FindSampleByID(ctx context.Context, id string, opts ...FindOption) (Sample, error) {
+	return read(ctx, r, func(repo Repo) (Sample, error) {
+		return repo.FindSampleByID(ctx, id, opts...)
+	})
+}
+
+func (r *Router) ListSamples(ctx context.Context, params ListSamplesParams) (SamplePage, error) {
+	return read(ctx, r, func(repo Repo) (SamplePage, error) {
+		return repo.ListSamples(ctx, params)
+	})
+}
+
+// Samples streams from a replica chosen when iteration starts. A replica that
+// cannot be reached before the first sample is retried on the primary; one
+// failing mid-scan is reported through the iterator, since the samples already
+// yielded cannot be taken back.
+func (r *Router) Samples(ctx context.Context) iter.Seq2[Sample, error] {
+	return func(yield func(Sample, error) bool) {
+		if rep := r.replica(ctx); rep != nil {
+			started, failed := false, false
+
+			for sample, err := range (Repo{dbtx: rep.pool}).Samples(ctx) {
+				if !started && unreachable(err) {
+					rep.markDown()
+					failed = true
+					break
+				}
+				started = true
+
+				if !yield(sample, err) {
+					return
+				}
+			}
+
+			if !failed {
+				return
+			}
+		}
+
+		for sample, err := range r.Repo.Samples(ctx) {
+			if !yield(sample, err) {
+				return
+			}
+		}
+	}
+}
@@ -1,6 +1,12 @@
 package database
 
+import "time"
+
 type Sample struct {
-	ID   string
-	Name string
+	ID        string
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Version   int64
+	DeletedAt *time.Time
 }
@@ -0,0 +1,521 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"iter"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+)
+
+const insertSamplesQuery = `INSERT INTO samples (id, name) SELECT * FROM unnest($1::uuid[], $2::text[]) RETURNING ` + sampleColumns
+
+// SQLRepo is the sample repository over database/sql with the lib/pq driver,
+// for services that cannot use pgx. It issues the same statements as Repo and
+// reports errors the same way. Batches, LISTEN and session-level advisory
+// locks need a pgx connection and are only offered by Repo.
+type SQLRepo struct {
+	db    *sql.DB
+	tx    *sql.Tx
+	depth int
+}
+
+type (
+	SQLDatabase    = SQLRepo
+	SQLTransaction = SQLRepo
+)
+
+func NewSQLDatabase(db *sql.DB) (SQLDatabase, error) {
+	return SQLRepo{db: db}, nil
+}
+
+// NewSQLTransaction returns a repository bound to tx. Committing or rolling
+// back tx is left to the caller, and WithTx on it opens a savepoint.
+func NewSQLTransaction(tx *sql.Tx) (SQLTransaction, error) {
+	return SQLRepo{tx: tx}, nil
+}
+
+type sqlQuerier interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}
+
+func (r SQLRepo) q() sqlQuerier {
+	if r.tx != nil {
+		return r.tx
+	}
+
+	return r.db
+}
+
+// exec runs a statement and returns the number of rows it affected.
+func (r SQLRepo) exec(ctx context.Context, query string, args ...any) (int64, error) {
+	result, err := r.q().ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r SQLRepo) InsertSample(ctx context.Context, name string) (Sample, error) {
+	sample, err := scanSample(r.q().QueryRowContext(ctx, insertSampleQuery, name))
+	if err != nil {
+		return Sample{}, wrapError("InsertSample", err)
+	}
+
+	return sample, nil
+}
+
+// InsertSampleIdempotent inserts a sample tagged with a client-supplied key.
+// Retrying with the same key returns the sample created by the first call
+// instead of inserting a duplicate, even if name differs.
+func (r SQLRepo) InsertSampleIdempotent(ctx context.Context, key string, name string) (Sample, error) {
+	return insertSampleIdempotent(func() (Sample, error) {
+		return scanSample(r.q().QueryRowContext(ctx, insertSampleIdempotentQuery, name, key))
+	}, func() (Sample, error) {
+		return scanSample(r.q().QueryRowContext(ctx, findSampleByKeyQuery, key))
+	})
+}
+
+// UpsertSampleByName returns the live sample called name, creating it if
+// needed. An existing sample is touched, which bumps its updated_at and
+// version.
+func (r SQLRepo) UpsertSampleByName(ctx context.Context, name string) (Sample, error) {
+	sample, err := scanSample(r.q().QueryRowContext(ctx, upsertSampleByNameQuery, name))
+	if err != nil {
+		return Sample{}, wrapError("UpsertSampleByName", err)
+	}
+
+	return sample, nil
+}
+
+// InsertSamples inserts names with a single INSERT of unnested arrays and
+// returns the samples in the same order as names. Like Repo.InsertSamples, it
+// inserts all of them or none.
+func (r SQLRepo) InsertSamples(ctx context.Context, names []string) ([]Sample, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, len(names))
+	for i := range names {
+		ids[i] = uuid.NewString()
+	}
+
+	rows, err := r.q().QueryContext(ctx, insertSamplesQuery, pq.Array(ids), pq.Array(names))
+	if err != nil {
+		return nil, wrapError("InsertSamples", err)
+	}
+	defer rows.Close()
+
+	inserted, err := collectSamples(rows)
+	if err != nil {
+		return nil, wrapError("InsertSamples", err)
+	}
+
+	return orderSamples(inserted, ids), nil
+}
+
+// CopySamples streams rows from src into samples with COPY. Each row holds a
+// single value, the sample name. lib/pq only supports COPY inside a
+// transaction, so outside one it runs in its own.
+func (r SQLRepo) CopySamples(ctx context.Context, src pgx.CopyFromSource) (int64, error) {
+	if r.tx == nil {
+		var n int64
+
+		err := r.WithTx(ctx, func(tx SQLRepo) error {
+			var err error
+			n, err = tx.CopySamples(ctx, src)
+			return err
+		})
+
+		return n, err
+	}
+
+	stmt, err := r.tx.PrepareContext(ctx, pq.CopyIn("samples", "name"))
+	if err != nil {
+		return 0, wrapError("CopySamples", err)
+	}
+	defer stmt.Close()
+
+	var n int64
+	for src.Next() {
+		values, err := src.Values()
+		if err != nil {
+			return 0, wrapError("CopySamples", err)
+		}
+
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return 0, wrapError("CopySamples", err)
+		}
+		n++
+	}
+
+	if err := src.Err(); err != nil {
+		return 0, wrapError("CopySamples", err)
+	}
+
+	// The final Exec flushes the buffered rows; constraint violations are
+	// reported here.
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return 0, wrapError("CopySamples", err)
+	}
+
+	return n, nil
+}
+
+func (r SQLRepo) UpdateSample(ctx context.Context, id string, name string) error {
+	n, err := r.exec(ctx, updateSampleQuery, name, id)
+	if err != nil {
+		return wrapError("UpdateSample", err)
+	}
+
+	if n == 0 {
+		return wrapError("UpdateSample", ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdateSampleIfVersion renames the sample only if it is still at
+// expectedVersion and returns it with its new version. It returns ErrConflict
+// when another update got there first.
+func (r SQLRepo) UpdateSampleIfVersion(ctx context.Context, id string, name string, expectedVersion int64) (Sample, error) {
+	sample, err := scanSample(r.q().QueryRowContext(ctx, updateSampleIfVersionQuery, name, id, expectedVersion))
+	if err == nil {
+		return sample, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Sample{}, wrapError("UpdateSampleIfVersion", err)
+	}
+
+	var exists bool
+
+	if err := r.q().QueryRowContext(ctx, sampleExistsQuery, id).Scan(&exists); err != nil {
+		return Sample{}, wrapError("UpdateSampleIfVersion", err)
+	}
+
+	if !exists {
+		return Sample{}, wrapError("UpdateSampleIfVersion", ErrNotFound)
+	}
+
+	return Sample{}, wrapError("UpdateSampleIfVersion", ErrConflict)
+}
+
+func (r SQLRepo) FindSampleByID(ctx context.Context, id string, opts ...FindOption) (Sample, error) {
+	return r.findSample(ctx, "FindSampleByID", id, "", opts)
+}
+
+func (r SQLRepo) findSample(ctx context.Context, op string, id string, lock string, opts []FindOption) (Sample, error) {
+	sample, err := scanSample(r.q().QueryRowContext(ctx, findSampleQuery(lock, opts), id))
+	if err != nil {
+		return Sample{}, wrapError(op, err)
+	}
+
+	return sample, nil
+}
+
+// FindSampleForUpdate reads the sample and locks it against concurrent
+// updates, deletes and locking reads until the transaction ends.
+func (r SQLRepo) FindSampleForUpdate(ctx context.Context, id string, opts ...LockOption) (Sample, error) {
+	return r.findSample(ctx, "FindSampleForUpdate", id, `FOR UPDATE`+newLockOptions("", opts).wait, nil)
+}
+
+// FindSampleForShare reads the sample and locks it against concurrent updates
+// and deletes while still allowing other FOR SHARE readers.
+func (r SQLRepo) FindSampleForShare(ctx context.Context, id string, opts ...LockOption) (Sample, error) {
+	return r.findSample(ctx, "FindSampleForShare", id, `FOR SHARE`+newLockOptions("", opts).wait, nil)
+}
+
+// ClaimSamples locks up to limit of the oldest live samples that no other
+// transaction holds, so concurrent workers never claim the same sample.
+func (r SQLRepo) ClaimSamples(ctx context.Context, limit int, opts ...LockOption) ([]Sample, error) {
+	o := newLockOptions(` SKIP LOCKED`, opts)
+
+	rows, err := r.q().QueryContext(ctx, claimSamplesQuery+o.wait, limit)
+	if err != nil {
+		return nil, wrapError("ClaimSamples", err)
+	}
+	defer rows.Close()
+
+	samples, err := collectSamples(rows)
+	if err != nil {
+		return nil, wrapError("ClaimSamples", err)
+	}
+
+	return samples, nil
+}
+
+func (r SQLRepo) ListSamples(ctx context.Context, params ListSamplesParams) (SamplePage, error) {
+	query, args, err := listSamplesQuery(params)
+	if err != nil {
+		return SamplePage{}, wrapError("ListSamples", err)
+	}
+
+	rows, err := r.q().QueryContext(ctx, query, args...)
+	if err != nil {
+		return SamplePage{}, wrapError("ListSamples", err)
+	}
+	defer rows.Close()
+
+	samples, err := collectSamples(rows)
+	if err != nil {
+		return SamplePage{}, wrapError("ListSamples", err)
+	}
+
+	return newSamplePage(samples, params.pageSize()), nil
+}
+
+// Samples streams every sample that is not soft-deleted, ordered by
+// (created_at, id) without loading them into memory. Breaking out of the loop
+// closes the rows and releases the connection.
+func (r SQLRepo) Samples(ctx context.Context) iter.Seq2[Sample, error] {
+	return func(yield func(Sample, error) bool) {
+		rows, err := r.q().QueryContext(ctx, samplesQuery)
+		if err != nil {
+			yield(Sample{}, wrapError("Samples", err))
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			sample, err := scanSample(rows)
+			if err != nil {
+				yield(Sample{}, wrapError("Samples", err))
+				return
+			}
+
+			if !yield(sample, nil) {
+				return
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			yield(Sample{}, wrapError("Samples", err))
+		}
+	}
+}
+
+// DeleteSamples removes every sample for good, including soft-deleted ones.
+func (r SQLRepo) DeleteSamples(ctx context.Context) error {
+	if _, err := r.exec(ctx, deleteSamplesQuery); err != nil {
+		return wrapError("DeleteSamples", err)
+	}
+
+	return nil
+}
+
+// DeleteSample soft-deletes the sample. It stays in the table, hidden from
+// reads, until it is restored or purged.
+func (r SQLRepo) DeleteSample(ctx context.Context, id string) error {
+	n, err := r.exec(ctx, deleteSampleQuery, id)
+	if err != nil {
+		return wrapError("DeleteSample", err)
+	}
+
+	if n == 0 {
+		return wrapError("DeleteSample", ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r SQLRepo) RestoreSample(ctx context.Context, id string) error {
+	n, err := r.exec(ctx, restoreSampleQuery, id)
+	if err != nil {
+		return wrapError("RestoreSample", err)
+	}
+
+	if n == 0 {
+		return wrapError("RestoreSample", ErrNotFound)
+	}
+
+	return nil
+}
+
+// PurgeDeletedBefore permanently removes samples soft-deleted before t and
+// reports how many were removed.
+func (r SQLRepo) PurgeDeletedBefore(ctx context.Context, t time.Time) (int64, error) {
+	n, err := r.exec(ctx, purgeDeletedBeforeQuery, t)
+	if err != nil {
+		return 0, wrapError("PurgeDeletedBefore", err)
+	}
+
+	return n, nil
+}
+
+// SetActor attributes the changes made in the rest of the current transaction
+// to actor in samples_history.
+func (r SQLRepo) SetActor(ctx context.Context, actor string) error {
+	if _, err := r.exec(ctx, setActorQuery, ActorSetting, actor); err != nil {
+		return wrapError("SetActor", err)
+	}
+
+	return nil
+}
+
+func (r SQLRepo) SampleHistory(ctx context.Context, id string) ([]SampleChange, error) {
+	rows, err := r.q().QueryContext(ctx, sampleHistoryQuery, id)
+	if err != nil {
+		return nil, wrapError("SampleHistory", err)
+	}
+	defer rows.Close()
+
+	changes, err := collectChanges(rows)
+	if err != nil {
+		return nil, wrapError("SampleHistory", err)
+	}
+
+	if len(changes) == 0 {
+		return nil, wrapError("SampleHistory", ErrNotFound)
+	}
+
+	return changes, nil
+}
+
+// FindSampleAsOf returns the sample as it was at t, according to its history.
+func (r SQLRepo) FindSampleAsOf(ctx context.Context, id string, t time.Time, opts ...FindOption) (Sample, error) {
+	var data []byte
+	if err := r.q().QueryRowContext(ctx, findSampleAsOfQuery, id, t).Scan(&data); err != nil {
+		return Sample{}, wrapError("FindSampleAsOf", err)
+	}
+
+	sample, err := sampleAsOf(data, opts)
+	if err != nil {
+		return Sample{}, wrapError("FindSampleAsOf", err)
+	}
+
+	return sample, nil
+}
+
+// AppendEvent writes an event to the outbox. Called on a transaction, the
+// event is committed or rolled back together with the rest of it. The payload
+// is sent as text, since lib/pq would encode a byte slice as bytea.
+func (r SQLRepo) AppendEvent(ctx context.Context, topic string, key string, payload json.RawMessage) (Event, error) {
+	event, err := scanEvent(r.q().QueryRowContext(ctx, appendEventQuery, topic, key, string(payload)))
+	if err != nil {
+		return Event{}, wrapError("AppendEvent", err)
+	}
+
+	return event, nil
+}
+
+// AdvisoryXactLock takes the transaction-level advisory lock for key, waiting
+// for other holders to release it.
+func (r SQLRepo) AdvisoryXactLock(ctx context.Context, key string) error {
+	if _, err := r.exec(ctx, advisoryXactLockQuery, AdvisoryKey(key)); err != nil {
+		return wrapError("AdvisoryXactLock", err)
+	}
+
+	return nil
+}
+
+// TryAdvisoryXactLock is like AdvisoryXactLock, but returns
+// ErrLockNotAvailable instead of waiting if the lock is already held.
+func (r SQLRepo) TryAdvisoryXactLock(ctx context.Context, key string) error {
+	var acquired bool
+	if err := r.q().QueryRowContext(ctx, tryAdvisoryXactLockQuery, AdvisoryKey(key)).Scan(&acquired); err != nil {
+		return wrapError("TryAdvisoryXactLock", err)
+	}
+
+	if !acquired {
+		return &Error{Op: "TryAdvisoryXactLock", Code: CodeLockNotAvailable, Err: ErrLockNotAvailable}
+	}
+
+	return nil
+}
+
+// WithTx runs fn in a transaction and commits it if fn returns nil. When the
+// SQLRepo is already bound to a transaction, the inner scope is a SAVEPOINT:
+// returning an error rolls back only the work done since the savepoint and
+// leaves the outer transaction usable.
+func (r SQLRepo) WithTx(ctx context.Context, fn func(SQLRepo) error) error {
+	if r.tx == nil {
+		return r.runInTx(ctx, "WithTx", nil, fn)
+	}
+
+	savepoint := "sp_" + strconv.Itoa(r.depth+1)
+
+	if _, err := r.tx.ExecContext(ctx, `SAVEPOINT `+savepoint); err != nil {
+		return wrapError("WithTx", err)
+	}
+
+	released := false
+	defer func() {
+		if !released {
+			_, _ = r.tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT `+savepoint)
+		}
+	}()
+
+	inner := SQLRepo{tx: r.tx, depth: r.depth + 1}
+
+	if actor, ok := actorFrom(ctx); ok {
+		if err := inner.SetActor(ctx, actor); err != nil {
+			return wrapError("WithTx", err)
+		}
+	}
+
+	if err := fn(inner); err != nil {
+		return err
+	}
+
+	if _, err := r.tx.ExecContext(ctx, `RELEASE SAVEPOINT `+savepoint); err != nil {
+		return wrapError("WithTx", err)
+	}
+	released = true
+
+	return nil
+}
+
+func (r SQLRepo) runInTx(ctx context.Context, op string, opts *sql.TxOptions, fn func(SQLRepo) error) error {
+	tx, err := r.db.BeginTx(ctx, opts)
+	if err != nil {
+		return wrapError(op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	inner := SQLRepo{tx: tx}
+
+	if actor, ok := actorFrom(ctx); ok {
+		if err := inner.SetActor(ctx, actor); err != nil {
+			return wrapError(op, err)
+		}
+	}
+
+	if err := fn(inner); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return wrapError(op, err)
+	}
+
+	return nil
+}
+
+// RunInSQLTx is RunInTx for database/sql: it runs fn in a transaction begun
+// on db with opts and retries it on SQLSTATE 40001 or 40P01 as policy allows.
+func RunInSQLTx(
+	ctx context.Context,
+	db *sql.DB,
+	opts *sql.TxOptions,
+	policy RetryPolicy,
+	fn func(SQLRepo) error,
+) error {
+	repo := SQLRepo{db: db}
+
+	return policy.retry(ctx, "RunInSQLTx", func() error {
+		return repo.runInTx(ctx, "RunInSQLTx", opts, fn)
+	})
+}
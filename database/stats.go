@@ -0,0 +1,233 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the query
+// latency histogram buckets used when none are given.
+var DefaultLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// QueryMetrics counts queries, batches and COPYs made through a pool and
+// records their latency, keyed by the repository method that issued them.
+// Install it with WithTracer and expose it with NewStatsHandler.
+type QueryMetrics struct {
+	buckets []float64
+
+	mu      sync.Mutex
+	methods map[string]*methodStats
+}
+
+type methodStats struct {
+	ok     uint64
+	errors uint64
+	counts []uint64
+	sum    float64
+}
+
+func NewQueryMetrics(buckets ...float64) (*QueryMetrics, error) {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+
+	if !slices.IsSorted(buckets) {
+		return nil, fmt.Errorf("latency buckets must be sorted: %v", buckets)
+	}
+
+	return &QueryMetrics{
+		buckets: slices.Clone(buckets),
+		methods: map[string]*methodStats{},
+	}, nil
+}
+
+var (
+	_ pgx.QueryTracer    = (*QueryMetrics)(nil)
+	_ pgx.BatchTracer    = (*QueryMetrics)(nil)
+	_ pgx.CopyFromTracer = (*QueryMetrics)(nil)
+)
+
+type metricsKey struct{}
+
+type metricsTrace struct {
+	start  time.Time
+	method string
+}
+
+func (m *QueryMetrics) begin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, metricsKey{}, metricsTrace{
+		start:  time.Now(),
+		method: strings.TrimPrefix(caller(), instrumentationName+"."),
+	})
+}
+
+func (m *QueryMetrics) end(ctx context.Context, err error) {
+	tr, ok := ctx.Value(metricsKey{}).(metricsTrace)
+	if !ok {
+		return
+	}
+
+	duration := time.Since(tr.start).Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.methods[tr.method]
+	if !ok {
+		stats = &methodStats{counts: make([]uint64, len(m.buckets))}
+		m.methods[tr.method] = stats
+	}
+
+	if err != nil {
+		stats.errors++
+	} else {
+		stats.ok++
+	}
+
+	for i, bound := range m.buckets {
+		if duration <= bound {
+			stats.counts[i]++
+		}
+	}
+
+	stats.sum += duration
+}
+
+func (m *QueryMetrics) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return m.begin(ctx)
+}
+
+func (m *QueryMetrics) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	m.end(ctx, data.Err)
+}
+
+func (m *QueryMetrics) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	return m.begin(ctx)
+}
+
+func (m *QueryMetrics) TraceBatchQuery(context.Context, *pgx.Conn, pgx.TraceBatchQueryData) {}
+
+func (m *QueryMetrics) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	m.end(ctx, data.Err)
+}
+
+func (m *QueryMetrics) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceCopyFromStartData) context.Context {
+	return m.begin(ctx)
+}
+
+func (m *QueryMetrics) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	m.end(ctx, data.Err)
+}
+
+func (m *QueryMetrics) write(w *bufio.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	methods := slices.Sorted(maps.Keys(m.methods))
+
+	writeHeader(w, "database_queries_total", "counter", "Queries issued, by repository method and outcome.")
+	for _, method := range methods {
+		stats := m.methods[method]
+		fmt.Fprintf(w, "database_queries_total{method=%s,status=\"ok\"} %d\n", quote(method), stats.ok)
+		fmt.Fprintf(w, "database_queries_total{method=%s,status=\"error\"} %d\n", quote(method), stats.errors)
+	}
+
+	writeHeader(w, "database_query_duration_seconds", "histogram", "Query latency, by repository method.")
+	for _, method := range methods {
+		stats := m.methods[method]
+		for i, bound := range m.buckets {
+			fmt.Fprintf(w, "database_query_duration_seconds_bucket{method=%s,le=%q} %d\n", quote(method), formatFloat(bound), stats.counts[i])
+		}
+
+		fmt.Fprintf(w, "database_query_duration_seconds_bucket{method=%s,le=\"+Inf\"} %d\n", quote(method), stats.ok+stats.errors)
+		fmt.Fprintf(w, "database_query_duration_seconds_sum{method=%s} %s\n", quote(method), formatFloat(stats.sum))
+		fmt.Fprintf(w, "database_query_duration_seconds_count{method=%s} %d\n", quote(method), stats.ok+stats.errors)
+	}
+}
+
+// StatsHandler serves the statistics of a pool, and optionally its
+// QueryMetrics, in the Prometheus text exposition format.
+type StatsHandler struct {
+	pool    *pgxpool.Pool
+	metrics *QueryMetrics
+}
+
+var _ http.Handler = (*StatsHandler)(nil)
+
+// NewStatsHandler returns a handler for pool. metrics may be nil, in which
+// case only the pool statistics are served.
+func NewStatsHandler(pool *pgxpool.Pool, metrics *QueryMetrics) (*StatsHandler, error) {
+	return &StatsHandler{pool: pool, metrics: metrics}, nil
+}
+
+func (h *StatsHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	stat := h.pool.Stat()
+
+	for _, gauge := range []struct {
+		name  string
+		help  string
+		value int32
+	}{
+		{"pgxpool_acquired_conns", "Connections currently acquired from the pool.", stat.AcquiredConns()},
+		{"pgxpool_idle_conns", "Connections currently idle in the pool.", stat.IdleConns()},
+		{"pgxpool_constructing_conns", "Connections currently being established.", stat.ConstructingConns()},
+		{"pgxpool_total_conns", "Connections currently open, whether acquired, idle or constructing.", stat.TotalConns()},
+		{"pgxpool_max_conns", "Maximum number of connections the pool will open.", stat.MaxConns()},
+	} {
+		writeHeader(bw, gauge.name, "gauge", gauge.help)
+		fmt.Fprintf(bw, "%s %d\n", gauge.name, gauge.value)
+	}
+
+	for _, counter := range []struct {
+		name  string
+		help  string
+		value int64
+	}{
+		{"pgxpool_acquires_total", "Successful connection acquires.", stat.AcquireCount()},
+		{"pgxpool_empty_acquires_total", "Acquires that had to wait because no idle connection was available.", stat.EmptyAcquireCount()},
+		{"pgxpool_canceled_acquires_total", "Acquires canceled by their context.", stat.CanceledAcquireCount()},
+		{"pgxpool_new_conns_total", "Connections opened by the pool.", stat.NewConnsCount()},
+		{"pgxpool_max_lifetime_destroys_total", "Connections closed for exceeding the maximum lifetime.", stat.MaxLifetimeDestroyCount()},
+		{"pgxpool_max_idle_destroys_total", "Connections closed for exceeding the maximum idle time.", stat.MaxIdleDestroyCount()},
+	} {
+		writeHeader(bw, counter.name, "counter", counter.help)
+		fmt.Fprintf(bw, "%s %d\n", counter.name, counter.value)
+	}
+
+	writeHeader(bw, "pgxpool_acquire_duration_seconds_total", "counter", "Total time spent acquiring connections.")
+	fmt.Fprintf(bw, "pgxpool_acquire_duration_seconds_total %s\n", formatFloat(stat.AcquireDuration().Seconds()))
+
+	if h.metrics != nil {
+		h.metrics.write(bw)
+	}
+}
+
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// quote escapes a label value as the exposition format requires.
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
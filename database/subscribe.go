@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ChangesChannel is the channel the samples_notify_change trigger publishes
+// on.
+const ChangesChannel = "samples_changes"
+
+type ChangeOp string
+
+const (
+	ChangeInsert   ChangeOp = "INSERT"
+	ChangeUpdate   ChangeOp = "UPDATE"
+	ChangeDelete   ChangeOp = "DELETE"
+	ChangeTruncate ChangeOp = "TRUNCATE"
+	// ChangeGap is delivered after the subscription reconnected. Changes made
+	// while it was disconnected are lost, so anything derived from samples
+	// must be treated as stale.
+	ChangeGap ChangeOp = "GAP"
+)
+
+// Change describes one insert, update or delete of a sample. Soft deletes and
+// restores are updates. ID and Version are empty for ChangeTruncate and
+// ChangeGap.
+type Change struct {
+	Op      ChangeOp `json:"op"`
+	ID      string   `json:"id"`
+	Version int64    `json:"version"`
+	// Err is the error that dropped the connection before a ChangeGap.
+	Err error `json:"-"`
+}
+
+var reconnectPolicy = RetryPolicy{
+	MinBackoff: 100 * time.Millisecond,
+	MaxBackoff: 5 * time.Second,
+}
+
+// Subscribe takes a connection out of pool, LISTENs on ChangesChannel and
+// delivers every change on the returned channel. If the connection drops it
+// reconnects with backoff and delivers a ChangeGap before resuming. The
+// channel is closed once ctx is done.
+func Subscribe(ctx context.Context, pool *pgxpool.Pool) (<-chan Change, error) {
+	conn, err := listen(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(chan Change)
+
+	go func() {
+		defer close(changes)
+
+		for {
+			err := receive(ctx, conn, changes)
+
+			conn.Close(context.Background())
+
+			if conn = reconnect(ctx, pool); conn == nil {
+				return
+			}
+
+			select {
+			case changes <- Change{Op: ChangeGap, Err: err}:
+			case <-ctx.Done():
+				conn.Close(context.Background())
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// listen hijacks a connection from pool so that it is never handed to other
+// callers while it is listening.
+func listen(ctx context.Context, pool *pgxpool.Pool) (*pgx.Conn, error) {
+	pc, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := pc.Hijack()
+
+	if _, err := conn.Exec(ctx, `LISTEN `+ChangesChannel); err != nil {
+		conn.Close(context.Background())
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func reconnect(ctx context.Context, pool *pgxpool.Pool) *pgx.Conn {
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reconnectPolicy.backoff(attempt)):
+		}
+
+		if conn, err := listen(ctx, pool); err == nil {
+			return conn
+		}
+	}
+}
+
+func receive(ctx context.Context, conn *pgx.Conn, changes chan<- Change) error {
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var change Change
+		if err := json.Unmarshal([]byte(notification.Payload), &change); err != nil {
+			// Not sent by the trigger; there is nothing to deliver.
+			continue
+		}
+
+		select {
+		case changes <- change:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
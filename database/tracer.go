@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Tracer logs every query, batch, COPY and connect made through a pool as a
+// slog record. Argument values are never logged, only their types. Anything
+// that takes at least SlowThreshold is logged at warn level; a zero
+// SlowThreshold disables slow-query logging.
+type Tracer struct {
+	logger        *slog.Logger
+	slowThreshold time.Duration
+}
+
+func NewTracer(logger *slog.Logger, slowThreshold time.Duration) (*Tracer, error) {
+	return &Tracer{logger: logger, slowThreshold: slowThreshold}, nil
+}
+
+var (
+	_ pgx.QueryTracer    = (*Tracer)(nil)
+	_ pgx.BatchTracer    = (*Tracer)(nil)
+	_ pgx.CopyFromTracer = (*Tracer)(nil)
+	_ pgx.ConnectTracer  = (*Tracer)(nil)
+)
+
+type traceKey struct{}
+
+type trace struct {
+	start  time.Time
+	caller string
+	sql    string
+	args   []any
+	table  string
+}
+
+func (t *Tracer) begin(ctx context.Context, tr trace) context.Context {
+	tr.start = time.Now()
+	tr.caller = caller()
+
+	return context.WithValue(ctx, traceKey{}, tr)
+}
+
+func (t *Tracer) end(ctx context.Context, msg string, err error, attrs ...slog.Attr) {
+	tr, _ := ctx.Value(traceKey{}).(trace)
+
+	duration := time.Since(tr.start)
+
+	level := slog.LevelDebug
+
+	switch {
+	case err != nil:
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any("error", err))
+	case t.slowThreshold > 0 && duration >= t.slowThreshold:
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.Bool("slow", true))
+	}
+
+	if tr.caller != "" {
+		attrs = append(attrs, slog.String("caller", tr.caller))
+	}
+
+	if tr.sql != "" {
+		attrs = append(attrs, slog.String("sql", tr.sql), slog.Any("args", redact(tr.args)))
+	}
+
+	if tr.table != "" {
+		attrs = append(attrs, slog.String("table", tr.table))
+	}
+
+	attrs = append(attrs, slog.Duration("duration", duration))
+
+	t.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (t *Tracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return t.begin(ctx, trace{sql: data.SQL, args: data.Args})
+}
+
+func (t *Tracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.end(ctx, "query", data.Err, slog.Int64("rows", data.CommandTag.RowsAffected()))
+}
+
+func (t *Tracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	return t.begin(ctx, trace{})
+}
+
+func (t *Tracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	tr, _ := ctx.Value(traceKey{}).(trace)
+
+	attrs := []slog.Attr{
+		slog.String("sql", data.SQL),
+		slog.Any("args", redact(data.Args)),
+		slog.Int64("rows", data.CommandTag.RowsAffected()),
+	}
+
+	level := slog.LevelDebug
+	if data.Err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any("error", data.Err))
+	}
+
+	if tr.caller != "" {
+		attrs = append(attrs, slog.String("caller", tr.caller))
+	}
+
+	t.logger.LogAttrs(ctx, level, "batch query", attrs...)
+}
+
+func (t *Tracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	t.end(ctx, "batch", data.Err)
+}
+
+func (t *Tracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	return t.begin(ctx, trace{table: data.TableName.Sanitize()})
+}
+
+func (t *Tracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	t.end(ctx, "copy from", data.Err, slog.Int64("rows", data.CommandTag.RowsAffected()))
+}
+
+func (t *Tracer) TraceConnectStart(ctx context.Context, data pgx.TraceConnectStartData) context.Context {
+	return t.begin(ctx, trace{})
+}
+
+func (t *Tracer) TraceConnectEnd(ctx context.Context, data pgx.TraceConnectEndData) {
+	t.end(ctx, "connect", data.Err)
+}
+
+// redact replaces argument values with their types so that logs never carry
+// user data.
+func redact(args []any) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		redacted[i] = fmt.Sprintf("%T", arg)
+	}
+
+	return redacted
+}
+
+// caller returns the first function on the stack outside pgx and the
+// internals of this package, which is the repository method or other Go call
+// that issued the query.
+func caller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+
+		if !strings.HasPrefix(frame.Function, "github.com/jackc/pgx/") && !internal(frame.Function) {
+			return trimClosures(frame.Function)
+		}
+
+		if !more {
+			return ""
+		}
+	}
+}
+
+// trimClosures strips the segments the compiler appends to the names of
+// function literals, such as .func1 or .gowrap1, so that a query issued from
+// the iterator returned by Repo.Samples is reported as Repo.Samples.
+func trimClosures(function string) string {
+	for {
+		i := strings.LastIndex(function, ".")
+		if i < 0 {
+			return function
+		}
+
+		elem := function[i+1:]
+		if trimmed, ok := strings.CutPrefix(elem, "func"); ok {
+			elem = trimmed
+		} else if trimmed, ok := strings.CutPrefix(elem, "gowrap"); ok {
+			elem = trimmed
+		}
+
+		if elem == "" || strings.TrimLeft(elem, "0123456789") != "" {
+			return function
+		}
+
+		function = function[:i]
+	}
+}
+
+// internal reports whether function is a tracer hook or an unexported
+// function or method of this package, such as Repo.findSample.
+func internal(function string) bool {
+	name, ok := strings.CutPrefix(function, instrumentationName+".")
+	if !ok {
+		return false
+	}
+
+	if strings.Contains(name, ").Trace") {
+		return true
+	}
+
+	elems := strings.Split(name, ".")
+
+	name = elems[0]
+	if len(elems) > 1 && !strings.HasPrefix(elems[1], "func") {
+		name = elems[1]
+	}
+
+	name = strings.TrimLeft(name, "(*")
+
+	return name != "" && unicode.IsLower(rune(name[0]))
+}
@@ -2,12 +2,14 @@ package database_test
 
 import (
 	"cmp"
+	"context"
 	"os"
 	"testing"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/otakakot/sample-go-postgresql-test/database"
+	"github.com/otakakot/sample-go-postgresql-test/database/repotest"
 )
 
 func TestNewTransaction(t *testing.T) {
@@ -18,6 +20,9 @@ func TestNewTransaction(t *testing.T) {
 		t.Fatalf("failed to parse dsn: %v", err)
 	}
 
+	// The concurrency subtest holds a transaction per worker at once.
+	cfg.MaxConns = 16
+
 	pool, err := pgxpool.NewWithConfig(t.Context(), cfg)
 	if err != nil {
 		t.Fatalf("failed to create pgx pool: %v", err)
@@ -27,44 +32,23 @@ func TestNewTransaction(t *testing.T) {
 		pool.Close()
 	})
 
-	tx, err := database.NewTransaction(pool)
-	if err != nil {
-		t.Fatalf("failed to create database: %v", err)
-	}
-
-	sample, err := tx.InsertSample(t.Context(), "test")
-	if err != nil {
-		t.Fatalf("failed to insert sample: %v", err)
-	}
-
-	updated := "updated-test"
-
-	if err := tx.UpdateSample(t.Context(), sample.ID, updated); err != nil {
-		t.Fatalf("failed to update sample: %v", err)
-	}
-
-	found, err := tx.FindSampleByID(t.Context(), sample.ID)
-	if err != nil {
-		t.Fatalf("failed to find sample by ID: %v", err)
-	}
+	// Each repository gets its own transaction, rolled back when its test
+	// ends, so nothing the suite does is visible outside it.
+	repotest.Run(t, func(t *testing.T) database.SampleRepository {
+		beginTx, err := pool.Begin(t.Context())
+		if err != nil {
+			t.Fatalf("failed to begin transaction: %v", err)
+		}
 
-	if found.ID != sample.ID || found.Name != updated {
-		t.Fatalf("found sample does not match inserted sample: got %+v, want %+v", found, sample)
-	}
+		t.Cleanup(func() {
+			_ = beginTx.Rollback(context.Background())
+		})
 
-	if samples, err := tx.ListSamples(t.Context()); err != nil {
-		t.Fatalf("failed to list samples: %v", err)
-	} else if len(samples) == 0 {
-		t.Fatal("no samples found")
-	}
+		tx, err := database.NewTransaction(beginTx)
+		if err != nil {
+			t.Fatalf("failed to create transaction: %v", err)
+		}
 
-	if err := tx.DeleteSamples(t.Context()); err != nil {
-		t.Fatalf("failed to delete all samples: %v", err)
-	}
-
-	if samples, err := tx.ListSamples(t.Context()); err != nil {
-		t.Fatalf("failed to list samples: %v", err)
-	} else if len(samples) != 0 {
-		t.Fatalf("expected no samples, but found %d", len(samples))
-	}
+		return tx
+	})
 }
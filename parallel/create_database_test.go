@@ -53,17 +53,17 @@ func TestCreateDatabase_Insert(t *testing.T) {
 		t.Fatalf("failed to create database: %v", err)
 	}
 
-	if _, err := db.InsertSample(t.Context(), "test"); err != nil {
+	if _, err := db.InsertSample(t.Context(), "test-"+uuid.NewString()); err != nil {
 		t.Fatalf("failed to insert sample: %v", err)
 	}
 
-	samples, err := db.ListSamples(t.Context())
+	page, err := db.ListSamples(t.Context(), database.ListSamplesParams{})
 	if err != nil {
 		t.Fatalf("failed to list samples: %v", err)
 	}
 
-	if len(samples) != 1 {
-		t.Fatalf("unexpected number of samples: got %d, want %d", len(samples), 1)
+	if len(page.Samples) != 1 {
+		t.Fatalf("unexpected number of samples: got %d, want %d", len(page.Samples), 1)
 	}
 }
 
@@ -106,19 +106,17 @@ func TestCreateDatabase_Select(t *testing.T) {
 
 	want := 2
 
-	for range want {
-		if _, err := db.InsertSample(t.Context(), "test"); err != nil {
-			t.Fatalf("failed to insert sample: %v", err)
-		}
+	if _, err := db.InsertSamples(t.Context(), SampleNames(want)); err != nil {
+		t.Fatalf("failed to insert samples: %v", err)
 	}
 
-	samples, err := db.ListSamples(t.Context())
+	page, err := db.ListSamples(t.Context(), database.ListSamplesParams{})
 	if err != nil {
 		t.Fatalf("failed to list samples: %v", err)
 	}
 
-	if len(samples) != want {
-		t.Fatalf("unexpected number of samples: got %d, want %d", len(samples), want)
+	if len(page.Samples) != want {
+		t.Fatalf("unexpected number of samples: got %d, want %d", len(page.Samples), want)
 	}
 }
 
@@ -159,23 +157,21 @@ func TestCreateDatabase_Delete(t *testing.T) {
 		t.Fatalf("failed to create database: %v", err)
 	}
 
-	for range 2 {
-		if _, err := db.InsertSample(t.Context(), "test"); err != nil {
-			t.Fatalf("failed to insert sample: %v", err)
-		}
+	if _, err := db.InsertSamples(t.Context(), SampleNames(2)); err != nil {
+		t.Fatalf("failed to insert samples: %v", err)
 	}
 
 	if err := db.DeleteSamples(t.Context()); err != nil {
 		t.Fatalf("failed to delete samples: %v", err)
 	}
 
-	samples, err := db.ListSamples(t.Context())
+	page, err := db.ListSamples(t.Context(), database.ListSamplesParams{})
 	if err != nil {
 		t.Fatalf("failed to list samples: %v", err)
 	}
 
-	if len(samples) != 0 {
-		t.Fatalf("expected no samples, but found %d", len(samples))
+	if len(page.Samples) != 0 {
+		t.Fatalf("expected no samples, but found %d", len(page.Samples))
 	}
 }
 
@@ -240,3 +236,12 @@ func Migrate(
 		}
 	}
 }
+
+func SampleNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = "test-" + uuid.NewString()
+	}
+
+	return names
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/otakakot/sample-go-postgresql-test/database"
 	"github.com/testcontainers/testcontainers-go"
@@ -38,17 +39,17 @@ func TestCreatePostgreSQL_Insert(t *testing.T) {
 		t.Fatalf("failed to create database: %v", err)
 	}
 
-	if _, err := db.InsertSample(t.Context(), "test"); err != nil {
+	if _, err := db.InsertSample(t.Context(), "test-"+uuid.NewString()); err != nil {
 		t.Fatalf("failed to insert sample: %v", err)
 	}
 
-	samples, err := db.ListSamples(t.Context())
+	page, err := db.ListSamples(t.Context(), database.ListSamplesParams{})
 	if err != nil {
 		t.Fatalf("failed to list samples: %v", err)
 	}
 
-	if len(samples) != 1 {
-		t.Fatalf("unexpected number of samples: got %d, want %d", len(samples), 1)
+	if len(page.Samples) != 1 {
+		t.Fatalf("unexpected number of samples: got %d, want %d", len(page.Samples), 1)
 	}
 }
 
@@ -79,19 +80,17 @@ func TestCreatePostgreSQL_Select(t *testing.T) {
 
 	want := 2
 
-	for range want {
-		if _, err := db.InsertSample(t.Context(), "test"); err != nil {
-			t.Fatalf("failed to insert sample: %v", err)
-		}
+	if _, err := db.InsertSamples(t.Context(), SampleNames(want)); err != nil {
+		t.Fatalf("failed to insert samples: %v", err)
 	}
 
-	samples, err := db.ListSamples(t.Context())
+	page, err := db.ListSamples(t.Context(), database.ListSamplesParams{})
 	if err != nil {
 		t.Fatalf("failed to list samples: %v", err)
 	}
 
-	if len(samples) != want {
-		t.Fatalf("unexpected number of samples: got %d, want %d", len(samples), want)
+	if len(page.Samples) != want {
+		t.Fatalf("unexpected number of samples: got %d, want %d", len(page.Samples), want)
 	}
 }
 
@@ -120,23 +119,21 @@ func TestCreatePostgreSQL_Delete(t *testing.T) {
 		t.Fatalf("failed to create database: %v", err)
 	}
 
-	for range 2 {
-		if _, err := db.InsertSample(t.Context(), "test"); err != nil {
-			t.Fatalf("failed to insert sample: %v", err)
-		}
+	if _, err := db.InsertSamples(t.Context(), SampleNames(2)); err != nil {
+		t.Fatalf("failed to insert samples: %v", err)
 	}
 
 	if err := db.DeleteSamples(t.Context()); err != nil {
 		t.Fatalf("failed to delete samples: %v", err)
 	}
 
-	samples, err := db.ListSamples(t.Context())
+	page, err := db.ListSamples(t.Context(), database.ListSamplesParams{})
 	if err != nil {
 		t.Fatalf("failed to list samples: %v", err)
 	}
 
-	if len(samples) != 0 {
-		t.Fatalf("expected no samples, but found %d", len(samples))
+	if len(page.Samples) != 0 {
+		t.Fatalf("expected no samples, but found %d", len(page.Samples))
 	}
 }
 
@@ -4,3 +4,114 @@ CREATE TABLE IF NOT EXISTS samples (
     created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
+
+-- Columns added after the table was first created are added here so that the
+-- schema can be applied again to an existing database.
+ALTER TABLE samples ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE samples ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE samples ADD COLUMN IF NOT EXISTS idempotency_key TEXT UNIQUE;
+
+-- Names are unique among live samples; a soft-deleted sample frees its name.
+-- A table-wide UNIQUE (name) constraint would take the index name and keep
+-- deleted names reserved, so it is dropped first.
+ALTER TABLE samples DROP CONSTRAINT IF EXISTS samples_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS samples_name_key ON samples (name) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS samples_created_at_id_idx ON samples (created_at, id);
+
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER samples_set_updated_at
+    BEFORE UPDATE ON samples
+    FOR EACH ROW
+    EXECUTE FUNCTION set_updated_at();
+
+CREATE OR REPLACE FUNCTION bump_version() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER samples_bump_version
+    BEFORE UPDATE ON samples
+    FOR EACH ROW
+    EXECUTE FUNCTION bump_version();
+
+-- Every change to samples is published on the samples_changes channel. The
+-- payload only identifies the row so that it stays under the 8000 byte limit.
+CREATE OR REPLACE FUNCTION notify_sample_change() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'TRUNCATE' THEN
+        PERFORM pg_notify('samples_changes', json_build_object('op', TG_OP)::text);
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('samples_changes', json_build_object('op', TG_OP, 'id', OLD.id, 'version', OLD.version)::text);
+    ELSE
+        PERFORM pg_notify('samples_changes', json_build_object('op', TG_OP, 'id', NEW.id, 'version', NEW.version)::text);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER samples_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON samples
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_sample_change();
+
+CREATE OR REPLACE TRIGGER samples_notify_truncate
+    AFTER TRUNCATE ON samples
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION notify_sample_change();
+
+-- Events are written in the same transaction as the change they describe and
+-- published by a relay, so a crash never loses one.
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    topic TEXT NOT NULL,
+    key TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (id) WHERE delivered_at IS NULL;
+
+-- Every change to samples is recorded with the row before and after it and
+-- the actor set by the application with set_config('app.actor', ..., true).
+CREATE TABLE IF NOT EXISTS samples_history (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    sample_id UUID NOT NULL,
+    operation TEXT NOT NULL,
+    old_row JSONB,
+    new_row JSONB,
+    actor TEXT,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS samples_history_sample_id_idx ON samples_history (sample_id, changed_at, id);
+
+CREATE OR REPLACE FUNCTION record_sample_history() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO samples_history (sample_id, operation, new_row, actor)
+        VALUES (NEW.id, TG_OP, to_jsonb(NEW), NULLIF(current_setting('app.actor', true), ''));
+    ELSIF TG_OP = 'UPDATE' THEN
+        INSERT INTO samples_history (sample_id, operation, old_row, new_row, actor)
+        VALUES (NEW.id, TG_OP, to_jsonb(OLD), to_jsonb(NEW), NULLIF(current_setting('app.actor', true), ''));
+    ELSE
+        INSERT INTO samples_history (sample_id, operation, old_row, actor)
+        VALUES (OLD.id, TG_OP, to_jsonb(OLD), NULLIF(current_setting('app.actor', true), ''));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER samples_record_history
+    AFTER INSERT OR UPDATE OR DELETE ON samples
+    FOR EACH ROW
+    EXECUTE FUNCTION record_sample_history();
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"sort"
 	"testing"
+	"time"
 
 	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
 	"github.com/jackc/pgx/v5"
@@ -58,13 +59,21 @@ func TestEmbeddedPostgresPgx(t *testing.T) {
 		}
 	}
 
-	var id string
+	var (
+		id        string
+		createdAt time.Time
+		updatedAt time.Time
+	)
 
 	// INSERT
-	if err := pool.QueryRow(t.Context(), `INSERT INTO samples (name) VALUES ($1) RETURNING id`, "test").Scan(&id); err != nil {
+	if err := pool.QueryRow(t.Context(), `INSERT INTO samples (name) VALUES ($1) RETURNING id, created_at, updated_at`, "test").Scan(&id, &createdAt, &updatedAt); err != nil {
 		t.Fatal(err)
 	}
 
+	if createdAt.IsZero() || !updatedAt.Equal(createdAt) {
+		t.Fatalf("unexpected timestamps: created_at=%v, updated_at=%v", createdAt, updatedAt)
+	}
+
 	// SELECT
 	if err := pool.QueryRow(t.Context(), `SELECT id FROM samples WHERE id = $1`, id).Scan(&id); err != nil {
 		t.Fatal(err)
@@ -75,6 +84,16 @@ func TestEmbeddedPostgresPgx(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// SELECT
+	var updated time.Time
+	if err := pool.QueryRow(t.Context(), `SELECT updated_at FROM samples WHERE id = $1`, id).Scan(&updated); err != nil {
+		t.Fatal(err)
+	}
+
+	if !updated.After(updatedAt) {
+		t.Fatalf("updated_at was not bumped: got %v, want after %v", updated, updatedAt)
+	}
+
 	// DELETE
 	if _, err := pool.Exec(t.Context(), `DELETE FROM samples WHERE id = $1`, id); err != nil {
 		t.Fatal(err)
@@ -128,13 +147,21 @@ func TestEmbeddedPostgresPq(t *testing.T) {
 		}
 	}
 
-	var id string
+	var (
+		id        string
+		createdAt time.Time
+		updatedAt time.Time
+	)
 
 	// INSERT
-	if err := db.QueryRowContext(t.Context(), `INSERT INTO samples (name) VALUES ($1) RETURNING id`, "test").Scan(&id); err != nil {
+	if err := db.QueryRowContext(t.Context(), `INSERT INTO samples (name) VALUES ($1) RETURNING id, created_at, updated_at`, "test").Scan(&id, &createdAt, &updatedAt); err != nil {
 		t.Fatal(err)
 	}
 
+	if createdAt.IsZero() || !updatedAt.Equal(createdAt) {
+		t.Fatalf("unexpected timestamps: created_at=%v, updated_at=%v", createdAt, updatedAt)
+	}
+
 	// SELECT
 	if err := db.QueryRowContext(t.Context(), `SELECT id FROM samples WHERE id = $1`, id).Scan(&id); err != nil {
 		t.Fatal(err)
@@ -145,6 +172,16 @@ func TestEmbeddedPostgresPq(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// SELECT
+	var updated time.Time
+	if err := db.QueryRowContext(t.Context(), `SELECT updated_at FROM samples WHERE id = $1`, id).Scan(&updated); err != nil {
+		t.Fatal(err)
+	}
+
+	if !updated.After(updatedAt) {
+		t.Fatalf("updated_at was not bumped: got %v, want after %v", updated, updatedAt)
+	}
+
 	// DELETE
 	if _, err := db.ExecContext(t.Context(), `DELETE FROM samples WHERE id = $1`, id); err != nil {
 		t.Fatal(err)
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/proullon/ramsql/driver"
@@ -40,16 +41,36 @@ func TestRamsql(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	var (
+		createdAt time.Time
+		updatedAt time.Time
+	)
+
 	// SELECT
-	if err := db.QueryRowContext(t.Context(), `SELECT id FROM samples WHERE id = $1`, id).Scan(&id); err != nil {
+	if err := db.QueryRowContext(t.Context(), `SELECT id, created_at, updated_at FROM samples WHERE id = $1`, id).Scan(&id, &createdAt, &updatedAt); err != nil {
 		t.Fatal(err)
 	}
 
+	if createdAt.IsZero() || updatedAt.IsZero() {
+		t.Fatalf("unexpected timestamps: created_at=%v, updated_at=%v", createdAt, updatedAt)
+	}
+
 	// UPDATE
-	if _, err := db.ExecContext(t.Context(), `UPDATE samples SET name = $1 WHERE id = $2`, "updated", id); err != nil {
+	// Not Supported: triggers, so updated_at is bumped in the query
+	if _, err := db.ExecContext(t.Context(), `UPDATE samples SET name = $1, updated_at = NOW() WHERE id = $2`, "updated", id); err != nil {
+		t.Fatal(err)
+	}
+
+	// SELECT
+	var updated time.Time
+	if err := db.QueryRowContext(t.Context(), `SELECT updated_at FROM samples WHERE id = $1`, id).Scan(&updated); err != nil {
 		t.Fatal(err)
 	}
 
+	if !updated.After(updatedAt) {
+		t.Fatalf("updated_at was not bumped: got %v, want after %v", updated, updatedAt)
+	}
+
 	// DELETE
 	if _, err := db.ExecContext(t.Context(), `DELETE FROM samples WHERE id = $1`, id); err != nil {
 		t.Fatal(err)
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -48,13 +49,21 @@ func TestStartedPostgresPgx(t *testing.T) {
 		_, _ = pool.Exec(context.Background(), `TRUNCATE TABLE samples`)
 	})
 
-	var id string
+	var (
+		id        string
+		createdAt time.Time
+		updatedAt time.Time
+	)
 
 	// INSERT
-	if err := pool.QueryRow(t.Context(), `INSERT INTO samples (name) VALUES ($1) RETURNING id`, "test").Scan(&id); err != nil {
+	if err := pool.QueryRow(t.Context(), `INSERT INTO samples (name) VALUES ($1) RETURNING id, created_at, updated_at`, "test").Scan(&id, &createdAt, &updatedAt); err != nil {
 		t.Fatal(err)
 	}
 
+	if createdAt.IsZero() || !updatedAt.Equal(createdAt) {
+		t.Fatalf("unexpected timestamps: created_at=%v, updated_at=%v", createdAt, updatedAt)
+	}
+
 	// SELECT
 	if err := pool.QueryRow(t.Context(), `SELECT id FROM samples WHERE id = $1`, id).Scan(&id); err != nil {
 		t.Fatal(err)
@@ -65,6 +74,16 @@ func TestStartedPostgresPgx(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// SELECT
+	var updated time.Time
+	if err := pool.QueryRow(t.Context(), `SELECT updated_at FROM samples WHERE id = $1`, id).Scan(&updated); err != nil {
+		t.Fatal(err)
+	}
+
+	if !updated.After(updatedAt) {
+		t.Fatalf("updated_at was not bumped: got %v, want after %v", updated, updatedAt)
+	}
+
 	// DELETE
 	if _, err := pool.Exec(t.Context(), `DELETE FROM samples WHERE id = $1`, id); err != nil {
 		t.Fatal(err)
@@ -109,13 +128,21 @@ func TestStartedPostgresPq(t *testing.T) {
 		_, _ = db.ExecContext(context.Background(), `TRUNCATE TABLE samples`)
 	})
 
-	var id string
+	var (
+		id        string
+		createdAt time.Time
+		updatedAt time.Time
+	)
 
 	// INSERT
-	if err := db.QueryRowContext(t.Context(), `INSERT INTO samples (name) VALUES ($1) RETURNING id`, "test").Scan(&id); err != nil {
+	if err := db.QueryRowContext(t.Context(), `INSERT INTO samples (name) VALUES ($1) RETURNING id, created_at, updated_at`, "test").Scan(&id, &createdAt, &updatedAt); err != nil {
 		t.Fatal(err)
 	}
 
+	if createdAt.IsZero() || !updatedAt.Equal(createdAt) {
+		t.Fatalf("unexpected timestamps: created_at=%v, updated_at=%v", createdAt, updatedAt)
+	}
+
 	// SELECT
 	if err := db.QueryRowContext(t.Context(), `SELECT id FROM samples WHERE id = $1`, id).Scan(&id); err != nil {
 		t.Fatal(err)
@@ -126,6 +153,16 @@ func TestStartedPostgresPq(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// SELECT
+	var updated time.Time
+	if err := db.QueryRowContext(t.Context(), `SELECT updated_at FROM samples WHERE id = $1`, id).Scan(&updated); err != nil {
+		t.Fatal(err)
+	}
+
+	if !updated.After(updatedAt) {
+		t.Fatalf("updated_at was not bumped: got %v, want after %v", updated, updatedAt)
+	}
+
 	// DELETE
 	if _, err := db.ExecContext(t.Context(), `DELETE FROM samples WHERE id = $1`, id); err != nil {
 		t.Fatal(err)
@@ -72,13 +72,21 @@ func TestTestcontainersPgx(t *testing.T) {
 	// 	t.Fatal(err)
 	// }
 
-	var id string
+	var (
+		id        string
+		createdAt time.Time
+		updatedAt time.Time
+	)
 
 	// INSERT
-	if err := pool.QueryRow(t.Context(), `INSERT INTO samples (name) VALUES ($1) RETURNING id`, "test").Scan(&id); err != nil {
+	if err := pool.QueryRow(t.Context(), `INSERT INTO samples (name) VALUES ($1) RETURNING id, created_at, updated_at`, "test").Scan(&id, &createdAt, &updatedAt); err != nil {
 		t.Fatal(err)
 	}
 
+	if createdAt.IsZero() || !updatedAt.Equal(createdAt) {
+		t.Fatalf("unexpected timestamps: created_at=%v, updated_at=%v", createdAt, updatedAt)
+	}
+
 	// SELECT
 	if err := pool.QueryRow(t.Context(), `SELECT id FROM samples WHERE id = $1`, id).Scan(&id); err != nil {
 		t.Fatal(err)
@@ -89,6 +97,16 @@ func TestTestcontainersPgx(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// SELECT
+	var updated time.Time
+	if err := pool.QueryRow(t.Context(), `SELECT updated_at FROM samples WHERE id = $1`, id).Scan(&updated); err != nil {
+		t.Fatal(err)
+	}
+
+	if !updated.After(updatedAt) {
+		t.Fatalf("updated_at was not bumped: got %v, want after %v", updated, updatedAt)
+	}
+
 	// DELETE
 	if _, err := pool.Exec(t.Context(), `DELETE FROM samples WHERE id = $1`, id); err != nil {
 		t.Fatal(err)
@@ -155,13 +173,21 @@ func TestTestcontainersPq(t *testing.T) {
 	// 	t.Fatal(err)
 	// }
 
-	var id string
+	var (
+		id        string
+		createdAt time.Time
+		updatedAt time.Time
+	)
 
 	// INSERT
-	if err := db.QueryRowContext(t.Context(), `INSERT INTO samples (name) VALUES ($1) RETURNING id`, "test").Scan(&id); err != nil {
+	if err := db.QueryRowContext(t.Context(), `INSERT INTO samples (name) VALUES ($1) RETURNING id, created_at, updated_at`, "test").Scan(&id, &createdAt, &updatedAt); err != nil {
 		t.Fatal(err)
 	}
 
+	if createdAt.IsZero() || !updatedAt.Equal(createdAt) {
+		t.Fatalf("unexpected timestamps: created_at=%v, updated_at=%v", createdAt, updatedAt)
+	}
+
 	// SELECT
 	if err := db.QueryRowContext(t.Context(), `SELECT id FROM samples WHERE id = $1`, id).Scan(&id); err != nil {
 		t.Fatal(err)
@@ -172,6 +198,16 @@ func TestTestcontainersPq(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// SELECT
+	var updated time.Time
+	if err := db.QueryRowContext(t.Context(), `SELECT updated_at FROM samples WHERE id = $1`, id).Scan(&updated); err != nil {
+		t.Fatal(err)
+	}
+
+	if !updated.After(updatedAt) {
+		t.Fatalf("updated_at was not bumped: got %v, want after %v", updated, updatedAt)
+	}
+
 	// DELETE
 	if _, err := db.ExecContext(t.Context(), `DELETE FROM samples WHERE id = $1`, id); err != nil {
 		t.Fatal(err)